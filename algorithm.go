@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// This file contains logic for predicting how the database server will execute
+// a given ALTER TABLE, based on a table of rules keyed on server flavor and
+// the types of clauses present in the ALTER.
+
+// instanceFlavor returns the flavor of instance, which the instance caches
+// for the lifetime of the process. An error is returned if the flavor cannot
+// be determined.
+func instanceFlavor(instance *tengo.Instance) (tengo.Flavor, error) {
+	flavor := instance.Flavor()
+	if !flavor.Known() {
+		if _, err := instance.Connect("", ""); err != nil {
+			return flavor, err
+		}
+		return flavor, fmt.Errorf("Unable to determine server version of %s", instance)
+	}
+	return flavor, nil
+}
+
+// supportsOnlineDDL returns true if the flavor supports ALGORITHM=INPLACE for
+// at least some operations: MySQL 5.6+ or MariaDB 10.0+.
+func supportsOnlineDDL(flavor tengo.Flavor) bool {
+	return flavor.MySQLAtLeast(5, 6, 0) || flavor.MariaDBAtLeast(10, 0, 0)
+}
+
+// supportsInstantAddColumn returns true if the flavor can add a column as the
+// last column of a table without rebuilding the table: MySQL 8.0.12+ or
+// MariaDB 10.3.2+.
+func supportsInstantAddColumn(flavor tengo.Flavor) bool {
+	return flavor.MySQLAtLeast(8, 0, 12) || flavor.MariaDBAtLeast(10, 3, 2)
+}
+
+// supportsInstantColumnAnywhere returns true if the flavor can add a column in
+// any position, or drop a column, without rebuilding the table: MySQL 8.0.29+
+// or MariaDB 10.4+.
+func supportsInstantColumnAnywhere(flavor tengo.Flavor) bool {
+	return flavor.MySQLAtLeast(8, 0, 29) || flavor.MariaDBAtLeast(10, 4, 0)
+}
+
+// supportsInstantMetadata returns true if the flavor can instantly perform
+// metadata-only column changes, such as changing a column's default value or
+// appending values to an ENUM: MySQL 8.0+ or MariaDB 10.3.2+.
+func supportsInstantMetadata(flavor tengo.Flavor) bool {
+	return flavor.MySQLAtLeast(8, 0, 0) || flavor.MariaDBAtLeast(10, 3, 2)
+}
+
+// supportsInplaceColumnExtension returns true if the flavor can extend a
+// VARCHAR column, or append values to an ENUM or SET, without rebuilding the
+// table: MySQL 5.7+ or MariaDB 10.2.2+.
+func supportsInplaceColumnExtension(flavor tengo.Flavor) bool {
+	return flavor.MySQLAtLeast(5, 7, 0) || flavor.MariaDBAtLeast(10, 2, 2)
+}
+
+// AlterAlgorithm enumerates the ways in which the database server may execute
+// an ALTER TABLE. Higher values are more expensive.
+type AlterAlgorithm int
+
+// Constants representing AlterAlgorithm values, in ascending order of cost.
+const (
+	AlgorithmInstant          AlterAlgorithm = iota // metadata-only change
+	AlgorithmInplaceNoRebuild                       // in-place, without rebuilding the table
+	AlgorithmInplaceRebuild                         // in-place, but table is rebuilt
+	AlgorithmCopy                                   // table is copied to a new table
+)
+
+func (algo AlterAlgorithm) String() string {
+	switch algo {
+	case AlgorithmInstant:
+		return "INSTANT"
+	case AlgorithmInplaceNoRebuild:
+		return "INPLACE without table rebuild"
+	case AlgorithmInplaceRebuild:
+		return "INPLACE with table rebuild"
+	default:
+		return "COPY"
+	}
+}
+
+// AlterPrediction represents how the database server is expected to execute
+// an ALTER TABLE.
+type AlterPrediction struct {
+	Algorithm    AlterAlgorithm
+	BlocksWrites bool
+}
+
+func (ap AlterPrediction) String() string {
+	blocking := "does not block writes"
+	if ap.BlocksWrites {
+		blocking = "blocks writes"
+	}
+	return fmt.Sprintf("ALGORITHM=%s, %s", ap.Algorithm, blocking)
+}
+
+// combine returns an AlterPrediction reflecting the more expensive aspects of
+// both ap and other, as is the case when multiple clauses are in one ALTER.
+func (ap AlterPrediction) combine(other AlterPrediction) AlterPrediction {
+	if other.Algorithm > ap.Algorithm {
+		ap.Algorithm = other.Algorithm
+	}
+	ap.BlocksWrites = ap.BlocksWrites || other.BlocksWrites
+	return ap
+}
+
+var (
+	predictInstant   = AlterPrediction{Algorithm: AlgorithmInstant}
+	predictNoRebuild = AlterPrediction{Algorithm: AlgorithmInplaceNoRebuild}
+	predictRebuild   = AlterPrediction{Algorithm: AlgorithmInplaceRebuild}
+	predictCopy      = AlterPrediction{Algorithm: AlgorithmCopy, BlocksWrites: true}
+)
+
+// PredictAlter returns a prediction of how a server of the supplied flavor
+// will execute the ALTER TABLE. The algorithm and lock clauses of mods
+// are also taken into account, since these override the server's choice.
+func PredictAlter(alter tengo.AlterTable, flavor tengo.Flavor, mods tengo.StatementModifiers) AlterPrediction {
+	if !supportsOnlineDDL(flavor) {
+		return predictCopy
+	}
+	result := predictInstant
+	for _, clause := range alter.Clauses {
+		result = result.combine(predictClause(clause, flavor))
+	}
+
+	switch strings.ToUpper(mods.AlgorithmClause) {
+	case "COPY":
+		result = result.combine(predictCopy)
+	case "INPLACE":
+		if result.Algorithm == AlgorithmInstant {
+			result.Algorithm = AlgorithmInplaceNoRebuild
+		}
+	}
+	switch strings.ToUpper(mods.LockClause) {
+	case "SHARED", "EXCLUSIVE":
+		result.BlocksWrites = true
+	}
+	return result
+}
+
+// predictClause returns a prediction for a single ALTER TABLE clause.
+func predictClause(clause tengo.TableAlterClause, flavor tengo.Flavor) AlterPrediction {
+	switch clause := clause.(type) {
+	case tengo.AddColumn:
+		if clause.Column.AutoIncrement {
+			return AlterPrediction{Algorithm: AlgorithmInplaceRebuild, BlocksWrites: true}
+		}
+		atEnd := !clause.PositionFirst && clause.PositionAfter == nil
+		if supportsInstantColumnAnywhere(flavor) || (atEnd && supportsInstantAddColumn(flavor)) {
+			return predictInstant
+		}
+		return predictRebuild
+	case tengo.DropColumn:
+		if supportsInstantColumnAnywhere(flavor) {
+			return predictInstant
+		}
+		return predictRebuild
+	case tengo.ModifyColumn:
+		return predictModifyColumn(clause, flavor)
+	case tengo.AddIndex:
+		if clause.Index.PrimaryKey {
+			return predictRebuild
+		}
+		return predictNoRebuild
+	case tengo.DropIndex:
+		if clause.Index.PrimaryKey {
+			// Dropping a primary key without adding a new one requires a copy.
+			// clause.Table is the "to" side, so it reveals whether there's a new PK.
+			if clause.Table.PrimaryKey == nil {
+				return predictCopy
+			}
+			return predictRebuild
+		}
+		return predictNoRebuild
+	case tengo.ChangeAutoIncrement, tengo.ChangeComment:
+		return predictNoRebuild
+	case tengo.ChangeCharSet:
+		return predictRebuild
+	case tengo.ChangeCreateOptions:
+		// Option names don't vary by flavor, so no need to supply real mods here
+		for _, opt := range strings.Fields(clause.Clause(tengo.StatementModifiers{})) {
+			if !strings.HasPrefix(opt, "STATS_") {
+				return predictRebuild
+			}
+		}
+		return predictNoRebuild
+	default: // includes tengo.ChangeStorageEngine
+		return predictCopy
+	}
+}
+
+// predictModifyColumn returns a prediction for a MODIFY COLUMN clause, which
+// depends on which aspects of the column are changing.
+func predictModifyColumn(mc tengo.ModifyColumn, flavor tengo.Flavor) AlterPrediction {
+	oldCol, newCol := mc.OldColumn, mc.NewColumn
+	if oldCol.CharSet != newCol.CharSet || oldCol.Collation != newCol.Collation || oldCol.AutoIncrement != newCol.AutoIncrement {
+		return predictCopy
+	}
+
+	result := predictInstant
+	if mc.PositionFirst || mc.PositionAfter != nil || oldCol.Nullable != newCol.Nullable {
+		result = result.combine(predictRebuild)
+	}
+	if oldCol.Default != newCol.Default || oldCol.OnUpdate != newCol.OnUpdate {
+		if supportsInstantMetadata(flavor) {
+			result = result.combine(predictInstant)
+		} else {
+			result = result.combine(predictNoRebuild)
+		}
+	}
+	if oldCol.Comment != newCol.Comment {
+		result = result.combine(predictNoRebuild)
+	}
+
+	oldType, newType := strings.ToLower(oldCol.TypeInDB), strings.ToLower(newCol.TypeInDB)
+	if oldType == newType {
+		return result
+	}
+	if (strings.HasPrefix(oldType, "enum(") && strings.HasPrefix(newType, "enum(")) || (strings.HasPrefix(oldType, "set(") && strings.HasPrefix(newType, "set(")) {
+		// Appending values to the end of the list is metadata-only. Any other change
+		// to the value list, including modifying the last value, requires a copy.
+		if strings.HasPrefix(newType, oldType[0:len(oldType)-1]+",") {
+			if supportsInstantMetadata(flavor) {
+				return result.combine(predictInstant)
+			} else if supportsInplaceColumnExtension(flavor) {
+				return result.combine(predictNoRebuild)
+			}
+		}
+		return predictCopy
+	}
+	if oldLen, newLen := varLength(oldType), varLength(newType); oldLen > 0 && newLen >= oldLen && supportsInplaceColumnExtension(flavor) {
+		// Extending a VARCHAR or VARBINARY is in-place, so long as the number of
+		// length bytes needed does not change. A column whose max byte length is
+		// under 256 uses 1 length byte; otherwise it uses 2.
+		bytesPerChar := charSetMaxBytes(newCol.CharSet)
+		if (oldLen*bytesPerChar < 256) == (newLen*bytesPerChar < 256) && strings.HasPrefix(oldType, "varchar") == strings.HasPrefix(newType, "varchar") {
+			return result.combine(predictNoRebuild)
+		}
+	}
+	return predictCopy
+}
+
+var reVarLength = regexp.MustCompile(`^var(?:char|binary)\((\d+)\)`)
+
+// varLength returns the declared length of a VARCHAR or VARBINARY column type,
+// or 0 for any other type.
+func varLength(colType string) int {
+	matches := reVarLength.FindStringSubmatch(colType)
+	if matches == nil {
+		return 0
+	}
+	length, _ := strconv.Atoi(matches[1])
+	return length
+}
+
+// charSetMaxBytes returns the maximum number of bytes per character in the
+// supplied character set. Unknown character sets, and the empty string used
+// for binary types, are treated as 1 byte per character.
+func charSetMaxBytes(charSet string) int {
+	switch strings.ToLower(charSet) {
+	case "utf8mb4", "utf16", "utf16le", "utf32", "gb18030":
+		return 4
+	case "utf8", "utf8mb3", "ujis", "eucjpms":
+		return 3
+	case "ucs2", "big5", "gbk", "sjis", "euckr", "gb2312", "cp932":
+		return 2
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestFlavorSeries(t *testing.T) {
+	cases := []struct {
+		version        string
+		versionComment string
+		expected       string
+	}{
+		{"5.6.38", "MySQL Community Server (GPL)", "mysql:5.6"},
+		{"5.7.21-log", "MySQL Community Server (GPL)", "mysql:5.7"},
+		{"5.7.21-20", "Percona Server (GPL), Release 20, Revision ed217b06ca3", "mysql:5.7"},
+		{"8.0.13", "MySQL Community Server - GPL", "mysql:8.0"},
+		{"10.1.26-MariaDB-0+deb9u1", "Debian 9.1", "mariadb:10.1"},
+		{"5.5.5-10.3.9-MariaDB-1:10.3", "mariadb.org binary distribution", "mariadb:10.3"},
+		{"five", "", "unknown"},
+	}
+	for _, c := range cases {
+		flavor := tengo.ParseFlavor(c.version, c.versionComment)
+		if actual := flavor.Series(); actual != c.expected {
+			t.Errorf("Expected %s Series() to return %s, instead found %s", flavor, c.expected, actual)
+		}
+	}
+	a := tengo.ParseFlavor("5.7.21", "")
+	b := tengo.ParseFlavor("5.7.23", "")
+	if a.Series() != b.Series() {
+		t.Errorf("Expected %s and %s to have same series, but they did not", a, b)
+	}
+}
+
+func TestPredictAlter(t *testing.T) {
+	mysql55 := tengo.Flavor{Vendor: tengo.VendorMySQL, Major: 5, Minor: 5, Patch: 60}
+	mysql56 := tengo.Flavor{Vendor: tengo.VendorMySQL, Major: 5, Minor: 6, Patch: 40}
+	mysql57 := tengo.Flavor{Vendor: tengo.VendorMySQL, Major: 5, Minor: 7, Patch: 22}
+	mysql80 := tengo.Flavor{Vendor: tengo.VendorMySQL, Major: 8, Minor: 0, Patch: 13}
+	mariadb103 := tengo.Flavor{Vendor: tengo.VendorMariaDB, Major: 10, Minor: 3, Patch: 9}
+
+	table := &tengo.Table{Name: "widgets"}
+	assertPrediction := func(flavor tengo.Flavor, mods tengo.StatementModifiers, expected AlterPrediction, clauses ...tengo.TableAlterClause) {
+		t.Helper()
+		alter := tengo.AlterTable{Table: table, Clauses: clauses}
+		if actual := PredictAlter(alter, flavor, mods); actual != expected {
+			stmt, _ := alter.Statement(mods)
+			t.Errorf("Expected PredictAlter for %s on %s to return %s, instead found %s", stmt, flavor, expected, actual)
+		}
+	}
+	var noMods tengo.StatementModifiers
+
+	col := &tengo.Column{Name: "name", TypeInDB: "varchar(40)", CharSet: "latin1"}
+	addAtEnd := tengo.AddColumn{Table: table, Column: col}
+	addFirst := tengo.AddColumn{Table: table, Column: col, PositionFirst: true}
+	assertPrediction(mysql55, noMods, predictCopy, addAtEnd)
+	assertPrediction(mysql57, noMods, predictRebuild, addAtEnd)
+	assertPrediction(mysql80, noMods, predictInstant, addAtEnd)
+	assertPrediction(mysql80, noMods, predictRebuild, addFirst)
+	assertPrediction(mariadb103, noMods, predictInstant, addAtEnd)
+
+	// Multiple clauses use the most expensive prediction
+	index := &tengo.Index{Name: "idx_name", Columns: []*tengo.Column{col}, SubParts: []uint16{0}}
+	addIndex := tengo.AddIndex{Table: table, Index: index}
+	assertPrediction(mysql80, noMods, predictNoRebuild, addAtEnd, addIndex)
+	assertPrediction(mysql56, noMods, predictRebuild, addAtEnd, addIndex)
+
+	// Explicit ALGORITHM or LOCK clauses override the server's choice
+	assertPrediction(mysql80, tengo.StatementModifiers{AlgorithmClause: "copy"}, predictCopy, addIndex)
+	assertPrediction(mysql80, tengo.StatementModifiers{LockClause: "SHARED"}, AlterPrediction{Algorithm: AlgorithmInplaceNoRebuild, BlocksWrites: true}, addIndex)
+
+	// Modifying column types
+	modify := func(oldType, newType, charSet string) tengo.ModifyColumn {
+		oldCol := &tengo.Column{Name: "col", TypeInDB: oldType, CharSet: charSet}
+		newCol := &tengo.Column{Name: "col", TypeInDB: newType, CharSet: charSet}
+		return tengo.ModifyColumn{Table: table, OldColumn: oldCol, NewColumn: newCol}
+	}
+	assertPrediction(mysql57, noMods, predictNoRebuild, modify("varchar(40)", "varchar(60)", "latin1"))
+	assertPrediction(mysql56, noMods, predictCopy, modify("varchar(40)", "varchar(60)", "latin1"))
+	assertPrediction(mysql57, noMods, predictCopy, modify("varchar(60)", "varchar(70)", "utf8mb4"))
+	assertPrediction(mysql57, noMods, predictNoRebuild, modify("varchar(100)", "varchar(200)", "utf8mb4"))
+	assertPrediction(mysql57, noMods, predictCopy, modify("varchar(60)", "varchar(40)", "latin1"))
+	assertPrediction(mysql57, noMods, predictCopy, modify("int(10) unsigned", "bigint(20) unsigned", ""))
+	assertPrediction(mysql57, noMods, predictNoRebuild, modify("enum('a','b')", "enum('a','b','c')", "latin1"))
+	assertPrediction(mysql80, noMods, predictInstant, modify("enum('a','b')", "enum('a','b','c')", "latin1"))
+	assertPrediction(mysql80, noMods, predictCopy, modify("enum('a','b')", "enum('c','a','b')", "latin1"))
+	assertPrediction(mysql80, noMods, predictCopy, modify("enum('a','b')", "enum('a','bc')", "latin1"))
+	assertPrediction(mysql57, noMods, predictCopy, modify("set('a','b')", "set('a','bc')", "latin1"))
+	assertPrediction(mysql80, noMods, predictInstant, modify("set('a','b')", "set('a','b','c')", "latin1"))
+
+	// Modifying other column attributes
+	defaultChange := modify("int(11)", "int(11)", "")
+	defaultChange.NewColumn.Default = tengo.ColumnDefaultValue("5")
+	assertPrediction(mysql56, noMods, predictNoRebuild, defaultChange)
+	assertPrediction(mysql80, noMods, predictInstant, defaultChange)
+	nullChange := modify("int(11)", "int(11)", "")
+	nullChange.NewColumn.Nullable = true
+	assertPrediction(mysql80, noMods, predictRebuild, nullChange)
+}
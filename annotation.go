@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// This file contains logic for structured comment annotations in *.sql files.
+// An annotation is a line of the form "-- skeema:name" or "-- skeema:name=value"
+// preceding the CREATE TABLE statement, which overrides the dir's
+// configuration when generating DDL for that one table.
+
+// Regexp for parsing annotation lines. Submatches:
+// [1] is the annotation name
+// [3] is the annotation value, if any
+var reAnnotation = regexp.MustCompile(`^\s*--\s*skeema:([a-z-]+)(=(.*?))?\s*$`)
+
+// annotationNames lists the supported annotations. Values indicate whether the
+// annotation is boolean, and may therefore be supplied without a value.
+var annotationNames = map[string]bool{
+	"ignore":                 true,
+	"allow-unsafe":           true,
+	"alter-algorithm":        false,
+	"alter-lock":             false,
+	"alter-wrapper":          false,
+	"alter-wrapper-min-size": false,
+	"ddl-wrapper":            false,
+	"safe-below-size":        false,
+}
+
+// TableAnnotations is an OptionValuer providing option overrides from the
+// annotations in a table's *.sql file. A value of "none" indicates the option
+// should be set to an empty string, for example to disable alter-wrapper for
+// the table.
+type TableAnnotations map[string]string
+
+// IsAnnotation returns true if line is formatted as an annotation, regardless
+// of whether the annotation name is supported.
+func IsAnnotation(line string) bool {
+	return reAnnotation.MatchString(line)
+}
+
+// ParseAnnotations parses the supplied annotation lines, returning an error
+// if any line is not a valid annotation.
+func ParseAnnotations(lines []string) (TableAnnotations, error) {
+	ta := make(TableAnnotations, len(lines))
+	for _, line := range lines {
+		matches := reAnnotation.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, fmt.Errorf("unable to parse annotation %q", strings.TrimSpace(line))
+		}
+		name, value := matches[1], matches[3]
+		isBool, ok := annotationNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown annotation skeema:%s", name)
+		} else if matches[2] == "" {
+			if !isBool {
+				return nil, fmt.Errorf("annotation skeema:%s requires a value", name)
+			}
+			value = "1"
+		} else if strings.ToLower(value) == "none" {
+			value = ""
+		}
+		ta[name] = value
+	}
+	return ta, nil
+}
+
+// OptionValue returns the value for the requested option, if the option was
+// overridden by an annotation.
+func (ta TableAnnotations) OptionValue(optionName string) (string, bool) {
+	if optionName == "ignore" {
+		return "", false
+	}
+	value, ok := ta[optionName]
+	return value, ok
+}
+
+// Ignore returns true if the table should be ignored when generating DDL, due
+// to a skeema:ignore annotation.
+func (ta TableAnnotations) Ignore() bool {
+	value, ok := ta["ignore"]
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "false", "off", "0", "":
+		return false
+	default:
+		return true
+	}
+}
+
+// Has returns true if the annotations override the named option.
+func (ta TableAnnotations) Has(optionName string) bool {
+	_, ok := ta.OptionValue(optionName)
+	return ok
+}
+
+// ignoresTable returns true if the target's *.sql file for the named table has
+// a skeema:ignore annotation.
+func (t *Target) ignoresTable(name string) bool {
+	return t.TableAnnotations[name].Ignore()
+}
+
+// filterIgnored removes statements from diff for any tables with a
+// skeema:ignore annotation, along with any such tables in
+// diff.UnsupportedTables.
+func (t *Target) filterIgnored(diff *tengo.SchemaDiff) {
+	filtered := diff.TableDiffs[:0]
+	for _, td := range diff.TableDiffs {
+		var name string
+		switch td := td.(type) {
+		case tengo.CreateTable:
+			name = td.Table.Name
+		case tengo.DropTable:
+			name = td.Table.Name
+		case tengo.AlterTable:
+			name = td.Table.Name
+		case tengo.RenameTable:
+			name = td.Table.Name
+		}
+		if !t.ignoresTable(name) {
+			filtered = append(filtered, td)
+		}
+	}
+	diff.TableDiffs = filtered
+
+	unsupported := diff.UnsupportedTables[:0]
+	for _, table := range diff.UnsupportedTables {
+		if !t.ignoresTable(table.Name) {
+			unsupported = append(unsupported, table)
+		}
+	}
+	diff.UnsupportedTables = unsupported
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	lines := []string{
+		"-- skeema:allow-unsafe",
+		"--skeema:alter-wrapper=none",
+		"-- skeema:alter-lock=shared  ",
+		"-- skeema:ignore=0",
+	}
+	ta, err := ParseAnnotations(lines)
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseAnnotations: %s", err)
+	}
+	expected := map[string]string{
+		"allow-unsafe":  "1",
+		"alter-wrapper": "",
+		"alter-lock":    "shared",
+	}
+	for name, expectedValue := range expected {
+		if value, ok := ta.OptionValue(name); !ok || value != expectedValue {
+			t.Errorf("Expected option %s to have value %q, instead found %q, %t", name, expectedValue, value, ok)
+		}
+	}
+	if ta.Ignore() {
+		t.Error("Expected skeema:ignore=0 to not ignore table, but it did")
+	}
+	if ta.Has("ignore") || ta.Has("ddl-wrapper") {
+		t.Error("Unexpected result from Has")
+	}
+	if ta, err := ParseAnnotations([]string{"-- skeema:ignore"}); err != nil || !ta.Ignore() {
+		t.Errorf("Expected skeema:ignore to ignore table; instead found %t, %v", ta.Ignore(), err)
+	}
+
+	badLines := []string{
+		"-- skeema:allow-unsafes",
+		"-- skeema:alter-wrapper",
+		"-- not an annotation",
+	}
+	for _, line := range badLines {
+		if _, err := ParseAnnotations([]string{line}); err == nil {
+			t.Errorf("Expected error parsing %q, but no error returned", line)
+		}
+	}
+}
+
+func TestSQLFileAnnotations(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	dir := &Dir{Path: tmpdir, Config: getConfig(map[string]string{})}
+	contents := "-- skeema:allow-unsafe=0\n-- skeema:alter-wrapper=none\nCREATE TABLE foo (id int);\n"
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, "foo.sql"), []byte(contents), 0644); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+
+	sf := SQLFile{Dir: dir, FileName: "foo.sql"}
+	if _, err := sf.Read(); err != nil {
+		t.Fatalf("Unexpected error from Read: %s", err)
+	}
+	if len(sf.Warnings) > 0 {
+		t.Errorf("Expected annotations to not cause warnings, instead found %v", sf.Warnings)
+	}
+	if sf.Contents != "CREATE TABLE `foo` (id int)" {
+		t.Errorf("Unexpected contents: %q", sf.Contents)
+	}
+	if ta := sf.TableAnnotations(); len(ta) != 2 || ta["allow-unsafe"] != "0" {
+		t.Errorf("Unexpected annotations: %v", ta)
+	}
+
+	// Writing a new SQLFile for the same path, as pull does, should preserve the
+	// existing annotations
+	sf = SQLFile{Dir: dir, FileName: "foo.sql", Contents: "CREATE TABLE `foo` (\n  `id` int(11) DEFAULT NULL\n)"}
+	if _, err := sf.Write(); err != nil {
+		t.Fatalf("Unexpected error from Write: %s", err)
+	}
+	newContents, err := ioutil.ReadFile(sf.Path())
+	if err != nil {
+		t.Fatalf("Unable to read file: %s", err)
+	}
+	if !strings.HasPrefix(string(newContents), "-- skeema:allow-unsafe=0\n-- skeema:alter-wrapper=none\nCREATE TABLE `foo`") {
+		t.Errorf("Annotations not preserved by Write; new contents: %q", newContents)
+	}
+
+	if err := ioutil.WriteFile(sf.Path(), []byte("-- skeema:bogus\nCREATE TABLE foo (id int);\n"), 0644); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if _, err := sf.Read(); err == nil {
+		t.Error("Expected error from Read for unknown annotation, but no error returned")
+	}
+}
+
+func TestFilterIgnored(t *testing.T) {
+	target := &Target{
+		TableAnnotations: map[string]TableAnnotations{
+			"ignored":    {"ignore": "1"},
+			"notignored": {"ignore": "0", "allow-unsafe": "1"},
+		},
+	}
+	diff := &tengo.SchemaDiff{
+		TableDiffs: []tengo.TableDiff{
+			tengo.CreateTable{Table: &tengo.Table{Name: "ignored"}},
+			tengo.AlterTable{Table: &tengo.Table{Name: "notignored"}},
+			tengo.DropTable{Table: &tengo.Table{Name: "other"}},
+			tengo.AlterTable{Table: &tengo.Table{Name: "ignored"}},
+		},
+		UnsupportedTables: []*tengo.Table{{Name: "ignored"}, {Name: "other"}},
+	}
+	target.filterIgnored(diff)
+	if len(diff.TableDiffs) != 2 {
+		t.Errorf("Expected 2 table diffs to remain, instead found %d", len(diff.TableDiffs))
+	} else if diff.TableDiffs[0].(tengo.AlterTable).Table.Name != "notignored" || diff.TableDiffs[1].(tengo.DropTable).Table.Name != "other" {
+		t.Errorf("Unexpected table diffs remaining: %+v", diff.TableDiffs)
+	}
+	if len(diff.UnsupportedTables) != 1 || diff.UnsupportedTables[0].Name != "other" {
+		t.Errorf("Unexpected unsupported tables remaining: %+v", diff.UnsupportedTables)
+	}
+}
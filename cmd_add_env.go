@@ -67,11 +67,12 @@ func AddEnvHandler(cfg *mycli.Config) error {
 		return NewExitValue(CodeBadConfig, "Command line did not specify which instance to connect to")
 	}
 
-	hostOptionFile.SetOptionValue(environment, "host", inst.Host)
-	if inst.Host == "localhost" && inst.SocketPath != "" {
+	host, port := InstanceRemoteHostPort(inst) // in case of SSH tunnel
+	hostOptionFile.SetOptionValue(environment, "host", host)
+	if host == "localhost" && inst.SocketPath != "" {
 		hostOptionFile.SetOptionValue(environment, "socket", inst.SocketPath)
 	} else {
-		hostOptionFile.SetOptionValue(environment, "port", strconv.Itoa(inst.Port))
+		hostOptionFile.SetOptionValue(environment, "port", strconv.Itoa(port))
 	}
 	if cfg.OnCLI("user") {
 		hostOptionFile.SetOptionValue(environment, "user", cfg.Get("user"))
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/skeema/mycli"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	summary := "Drop orphaned temporary schemas left behind by interrupted runs"
+	desc := `Finds and drops unique temporary schemas, created by runs with the
+unique-temp-schema option enabled, that were left behind by runs that crashed or
+were interrupted. Such schemas are identified by their name, which consists of
+the temp-schema option value followed by a suffix containing the creation time
+and process ID. Only temporary schemas created longer ago than the duration
+supplied by --older-than are dropped. Temporary schemas containing tables with
+rows are never dropped.
+
+Every database instance referenced by the current directory tree is checked, as
+well as the workspace-host instance, if one is configured.
+
+You may optionally pass an environment name as a CLI option. This will affect
+which section of .skeema config files is used for determining which database
+instances to check. If no environment name is supplied, the default is
+"production".`
+
+	cmd := mycli.NewCommand("cleanup", summary, desc, CleanupHandler)
+	cmd.AddOption(mycli.StringOption("older-than", 0, "24h", `Only drop temp schemas created longer ago than this duration (e.g. "7d" or "36h")`))
+	cmd.AddOption(mycli.BoolOption("dry-run", 0, false, "Output temp schemas that would be dropped, but don't drop them"))
+	cmd.AddArg("environment", "production", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// CleanupHandler is the handler method for `skeema cleanup`
+func CleanupHandler(cfg *mycli.Config) error {
+	AddGlobalConfigFiles(cfg)
+	dir, err := NewDir(".", cfg)
+	if err != nil {
+		return err
+	}
+	olderThan, err := parseAge(dir.Config.Get("older-than"))
+	if err != nil {
+		return NewExitValue(CodeBadConfig, "Option older-than: %s", err)
+	}
+	cutoff := time.Now().Add(-olderThan)
+	dryRun := dir.Config.GetBool("dry-run")
+
+	instances := make(map[string]*tengo.Instance)
+	tempSchemaNames := make(map[string]map[string]bool)
+	var errCount, dropCount int
+	if err := findCleanupInstances(dir, instances, tempSchemaNames); err != nil {
+		log.Error(err)
+		errCount++
+	}
+
+	for key, instance := range instances {
+		schemas, err := instance.Schemas()
+		if err != nil {
+			log.Errorf("Unable to list schemas on %s: %s", instance, err)
+			errCount++
+			continue
+		}
+		for _, schema := range schemas {
+			var createdAt time.Time
+			var found bool
+			for base := range tempSchemaNames[key] {
+				if createdAt, found = parseUniqueTempSchemaName(base, schema.Name); found {
+					break
+				}
+			}
+			if !found {
+				continue
+			} else if createdAt.After(cutoff) {
+				log.Debugf("Keeping %s on %s: created at %s", schema.Name, instance, createdAt)
+				continue
+			}
+			fmt.Printf("%s;\n", schema.DropStatement())
+			dropCount++
+			if dryRun {
+				continue
+			}
+			if err := instance.DropSchema(schema, true); err != nil {
+				log.Errorf("Unable to drop temp schema %s on %s: %s", schema.Name, instance, err)
+				errCount++
+			}
+		}
+	}
+	os.Stderr.WriteString("\n")
+
+	if errCount > 0 {
+		return NewExitValue(CodePartialError, "Skipped %d operation%s due to error%s", errCount, pluralS(errCount), pluralS(errCount))
+	}
+	if dryRun && dropCount > 0 {
+		return NewExitValue(CodeDifferencesFound, "")
+	}
+	return nil
+}
+
+// findCleanupInstances recursively walks dir and its subdirs, populating
+// instances with every instance referenced, keyed by instance string. The
+// workspace-host instance is included if configured. tempSchemaNames is
+// populated with the set of temp-schema option values in use for each
+// instance.
+func findCleanupInstances(dir *Dir, instances map[string]*tengo.Instance, tempSchemaNames map[string]map[string]bool) error {
+	dirInstances, err := dir.Instances()
+	if err != nil {
+		return fmt.Errorf("Unable to obtain instances for %s: %s", dir, err)
+	}
+	if dir.Config.Changed("workspace-host") {
+		workspace, err := dir.WorkspaceInstance()
+		if err != nil {
+			return err
+		}
+		dirInstances = append(dirInstances, workspace)
+	}
+	for _, instance := range dirInstances {
+		key := instance.String()
+		instances[key] = instance
+		if tempSchemaNames[key] == nil {
+			tempSchemaNames[key] = make(map[string]bool)
+		}
+		tempSchemaNames[key][dir.Config.Get("temp-schema")] = true
+	}
+
+	subdirs, err := dir.Subdirs()
+	if err != nil {
+		return err
+	}
+	for _, subdir := range subdirs {
+		if subdir.BaseName()[0] == '.' {
+			continue
+		}
+		if err := findCleanupInstances(subdir, instances, tempSchemaNames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -50,12 +50,18 @@ func clonePushOptionsToDiff() {
 	descRewrites := map[string]string{
 		"allow-unsafe":    "Permit generating ALTER or DROP operations that are potentially destructive",
 		"alter-wrapper":   "Output ALTER TABLEs as shell commands rather than just raw DDL; see manual for template vars",
+		"check-data":      "Permit generating unsafe column modifications if querying existing data proves no values would be lost",
 		"brief":           "Don't output DDL to STDOUT; instead output list of instances with at least one difference",
 		"safe-below-size": "Always permit generating destructive operations for tables below this size in bytes",
 	}
 	hiddenRewrites := map[string]bool{
-		"brief":   false,
-		"dry-run": true,
+		"brief":                false,
+		"canary":               true,
+		"dry-run":              true,
+		"check-replicas":       true,
+		"replica-hosts":        true,
+		"replica-wait-timeout": true,
+		"replica-push":         true,
 	}
 
 	diffOptions := diff.Options()
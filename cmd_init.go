@@ -18,7 +18,8 @@ func init() {
 For each schema on the instance (or just the single schema specified by
 --schema), a subdir with a .skeema config file will be created. Each directory
 will be populated with .sql files containing CREATE TABLE statements for every
-table in the schema.
+table in the schema. With --group-shards, schemas with identical structure whose
+names differ only in a numeric suffix share a single subdir instead.
 
 You may optionally pass an environment name as a CLI option. This will affect
 which section of .skeema config files the host and schema names are written to.
@@ -34,6 +35,8 @@ section of the file.`
 	cmd.AddOption(mycli.StringOption("dir", 'd', "<hostname>", "Base dir to use for this host's schemas"))
 	cmd.AddOption(mycli.StringOption("schema", 0, "", "Only import the one specified schema; skip creation of subdirs for each schema"))
 	cmd.AddOption(mycli.BoolOption("include-auto-inc", 0, false, "Include starting auto-inc values in table files"))
+	cmd.AddOption(mycli.BoolOption("dry-run", 0, false, "Output files that would be created, but don't create them"))
+	cmd.AddOption(mycli.BoolOption("group-shards", 0, false, "Place schemas with identical structure, differing only in a numeric name suffix, in a single subdir"))
 	cmd.AddArg("environment", "production", false)
 	CommandSuite.AddSubCommand(cmd)
 }
@@ -91,36 +94,63 @@ func InitHandler(cfg *mycli.Config) error {
 		return NewExitValue(CodeBadConfig, "Environment name \"%s\" is invalid", environment)
 	}
 
-	// Build list of schemas
+	// Build list of schemas. If schema-name-template is in use, --schema refers
+	// to the schema name before applying the template, and only schemas matching
+	// the template are included. The include-schemas and exclude-schemas filters
+	// only apply when --schema is not used.
 	var schemas []*tengo.Schema
 	if onlySchema != "" {
-		if !inst.HasSchema(onlySchema) {
-			return NewExitValue(CodeBadConfig, "Schema %s does not exist on instance %s", onlySchema, inst)
+		actualName, err := hostDir.ActualSchemaName(onlySchema)
+		if err != nil {
+			return NewExitValue(CodeBadConfig, "%s", err)
+		}
+		if !inst.HasSchema(actualName) {
+			return NewExitValue(CodeBadConfig, "Schema %s does not exist on instance %s", actualName, inst)
 		}
-		s, err := inst.Schema(onlySchema)
+		s, err := inst.Schema(actualName)
 		if err != nil {
-			return NewExitValue(CodeFatalError, "Cannot examine schema %s: %s", onlySchema, err)
+			return NewExitValue(CodeFatalError, "Cannot examine schema %s: %s", actualName, err)
 		}
 		schemas = []*tengo.Schema{s}
 	} else {
-		var err error
-		schemas, err = inst.Schemas()
+		allSchemas, err := inst.Schemas()
 		if err != nil {
 			return NewExitValue(CodeFatalError, "Cannot examine schemas on %s: %s", inst, err)
 		}
+		for _, s := range allSchemas {
+			if isSoftDropSchemaName(hostDir.Config, s.Name) {
+				continue
+			}
+			if _, ok, err := hostDir.LogicalSchemaName(s.Name); err != nil {
+				return NewExitValue(CodeBadConfig, "%s", err)
+			} else if !ok {
+				continue
+			}
+			if included, err := hostDir.SchemaIncluded(s.Name); err != nil {
+				return NewExitValue(CodeBadConfig, "%s", err)
+			} else if included {
+				schemas = append(schemas, s)
+			}
+		}
 	}
 
 	// Figure out what needs to go in the hostDir's .skeema file.
 	hostOptionFile := mycli.NewFile(hostDir.Path, ".skeema")
-	hostOptionFile.SetOptionValue(environment, "host", inst.Host)
-	if inst.Host == "localhost" && inst.SocketPath != "" {
+	host, port := InstanceRemoteHostPort(inst) // in case of SSH tunnel
+	hostOptionFile.SetOptionValue(environment, "host", host)
+	if host == "localhost" && inst.SocketPath != "" {
 		hostOptionFile.SetOptionValue(environment, "socket", inst.SocketPath)
 	} else {
-		hostOptionFile.SetOptionValue(environment, "port", strconv.Itoa(inst.Port))
+		hostOptionFile.SetOptionValue(environment, "port", strconv.Itoa(port))
 	}
 	if cfg.OnCLI("user") {
 		hostOptionFile.SetOptionValue(environment, "user", cfg.Get("user"))
 	}
+	for _, name := range []string{"schema-name-template", "include-schemas", "exclude-schemas"} {
+		if cfg.OnCLI(name) {
+			hostOptionFile.SetOptionValue(environment, name, cfg.Get(name))
+		}
+	}
 	if !separateSchemaSubdir {
 		// schema name is placed outside of any named section/environment since the
 		// default assumption is that schema names match between environments
@@ -143,69 +173,176 @@ func InitHandler(cfg *mycli.Config) error {
 	verb := "Using"
 	var suffix string
 	if wasNewDir {
-		verb = "Creating and using"
+		verb = hostDir.fsVerb("Creating and using", "Would create and use")
 	}
 	if !separateSchemaSubdir {
 		suffix = "; skipping schema-level subdirs"
 	}
 	log.Infof("%s host dir %s for %s%s\n", verb, hostDir.Path, inst, suffix)
 
-	// Iterate over the schemas. For each one, create a dir with .skeema and *.sql files
+	// If requested, group schemas with identical structure, which differ only in
+	// a numeric name suffix
+	var groups []shardGroup
+	groupBySchema := make(map[string]*shardGroup)
+	if separateSchemaSubdir && cfg.GetBool("group-shards") {
+		if groups, err = findShardGroups(hostDir, schemas); err != nil {
+			return err
+		}
+		for n := range groups {
+			for _, name := range groups[n].SchemaNames {
+				groupBySchema[name] = &groups[n]
+			}
+		}
+	}
+
+	// Iterate over the schemas. For each one, create a dir with .skeema and *.sql
+	// files. For grouped schemas, only one dir is created per group.
+	populatedGroups := make(map[*shardGroup]bool)
 	for _, s := range schemas {
+		name, ok, err := hostDir.LogicalSchemaName(s.Name)
+		if err != nil {
+			return err
+		} else if !ok {
+			name = s.Name
+		}
+		if group := groupBySchema[name]; group != nil {
+			if !populatedGroups[group] {
+				populatedGroups[group] = true
+				if err := PopulateShardGroupDir(s, hostDir, *group); err != nil {
+					return err
+				}
+			}
+			continue
+		}
 		if err := PopulateSchemaDir(s, hostDir, separateSchemaSubdir); err != nil {
 			return err
 		}
 	}
 
+	if hostDir.DryRun() && dryRunChanges > 0 {
+		return NewExitValue(CodeDifferencesFound, "")
+	}
 	return nil
 }
 
+// findShardGroups fingerprints each of schemas, and returns groups of schemas
+// with identical structure, keyed by schema name after reversing any
+// schema-name-template. Any schema which shares a name prefix with a group,
+// but has a different structure than the group, is logged as a warning.
+func findShardGroups(hostDir *Dir, schemas []*tengo.Schema) ([]shardGroup, error) {
+	fingerprints := make(map[string]string, len(schemas))
+	for _, s := range schemas {
+		if isTempSchemaName(hostDir.Config, s.Name) {
+			continue
+		}
+		name, ok, err := hostDir.LogicalSchemaName(s.Name)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			name = s.Name
+		}
+		if fingerprints[name], err = schemaFingerprint(s); err != nil {
+			return nil, NewExitValue(CodeFatalError, "Cannot obtain table information for %s: %s", s.Name, err)
+		}
+	}
+	groups := groupShards(fingerprints)
+	for _, group := range groups {
+		for _, name := range group.Deviants {
+			log.Warnf("Schema %s differs in structure from the other %d schemas of shard group %s; placing it in its own directory", name, len(group.SchemaNames), group.DirName)
+		}
+	}
+	return groups, nil
+}
+
 // PopulateSchemaDir writes out *.sql files for all tables in the specified
 // schema. If makeSubdir==true, a subdir with name matching the schema name
 // will be created, and a .skeem option file will be created. Otherwise, the
 // *.sql files will be put in parentDir, and it will be the caller's
 // responsibility to ensure its .skeema option file exists and maps to the
-// correct schema name.
+// correct schema name. If parentDir uses schema-name-template, the subdir and
+// its schema option are named by applying the template in reverse.
 func PopulateSchemaDir(s *tengo.Schema, parentDir *Dir, makeSubdir bool) error {
-	// Ignore any attempt to populate a dir for the temp schema
-	if s.Name == parentDir.Config.Get("temp-schema") {
+	// Ignore any attempt to populate a dir for the temp schema, including any
+	// unique temp schemas from concurrent runs
+	if isTempSchemaName(parentDir.Config, s.Name) {
 		return nil
 	}
 
 	var schemaDir *Dir
-	var err error
 	if makeSubdir {
-		// Put a .skeema file with the schema name in it. This is placed outside of
-		// any named section/environment since the default assumption is that schema
-		// names match between environments.
-		optionFile := mycli.NewFile(".skeema")
-		optionFile.SetOptionValue("", "schema", s.Name)
-		if overridesCharSet, overridesCollation, err := s.OverridesServerCharSet(); err == nil {
-			if overridesCharSet {
-				optionFile.SetOptionValue("", "default-character-set", s.CharSet)
-			}
-			if overridesCollation {
-				optionFile.SetOptionValue("", "default-collation", s.Collation)
-			}
+		name, ok, err := parentDir.LogicalSchemaName(s.Name)
+		if err != nil {
+			return err
+		} else if !ok {
+			name = s.Name
 		}
-		if schemaDir, err = parentDir.CreateSubdir(s.Name, optionFile); err != nil {
-			return NewExitValue(CodeCantCreate, "Unable to use directory %s for schema %s: %s", path.Join(parentDir.Path, s.Name), s.Name, err)
+		if schemaDir, err = createSchemaSubdir(s, parentDir, name, name); err != nil {
+			return err
 		}
 	} else {
 		schemaDir = parentDir
-		if sqlfiles, err := schemaDir.SQLFiles(); err != nil {
-			return fmt.Errorf("Unable to list files in %s: %s", schemaDir.Path, err)
-		} else if len(sqlfiles) > 0 {
-			return fmt.Errorf("%s already contains *.sql files; cannot proceed", schemaDir.Path)
+		// In dry-run mode, the caller may not have actually created the dir, in
+		// which case there are no *.sql files to check for
+		if schemaDir.Exists() || !schemaDir.DryRun() {
+			if sqlfiles, err := schemaDir.SQLFiles(); err != nil {
+				return fmt.Errorf("Unable to list files in %s: %s", schemaDir.Path, err)
+			} else if len(sqlfiles) > 0 {
+				return fmt.Errorf("%s already contains *.sql files; cannot proceed", schemaDir.Path)
+			}
 		}
 	}
 
 	log.Infof("Populating %s", schemaDir.Path)
+	return populateTableFiles(s, schemaDir)
+}
+
+// PopulateShardGroupDir creates a subdir of parentDir for a group of schemas
+// with identical structure, as determined by groupShards. The subdir's .skeema
+// file lists all of the group's schema names, and its *.sql files are based on
+// s, which should be one of the schemas in the group.
+func PopulateShardGroupDir(s *tengo.Schema, parentDir *Dir, group shardGroup) error {
+	schemaDir, err := createSchemaSubdir(s, parentDir, group.DirName, group.String())
+	if err != nil {
+		return err
+	}
+	log.Infof("Populating %s using %s, for %d schemas with identical structure", schemaDir.Path, s.Name, len(group.SchemaNames))
+	return populateTableFiles(s, schemaDir)
+}
+
+// createSchemaSubdir creates a subdir of parentDir called name, with a .skeema
+// file setting the schema option to schemaValue. Any non-default character set
+// or collation of s is also included in the .skeema file.
+func createSchemaSubdir(s *tengo.Schema, parentDir *Dir, name, schemaValue string) (*Dir, error) {
+	// Put a .skeema file with the schema name in it. This is placed outside of
+	// any named section/environment since the default assumption is that schema
+	// names match between environments.
+	optionFile := mycli.NewFile(".skeema")
+	optionFile.SetOptionValue("", "schema", schemaValue)
+	if overridesCharSet, overridesCollation, err := s.OverridesServerCharSet(); err == nil {
+		if overridesCharSet {
+			optionFile.SetOptionValue("", "default-character-set", s.CharSet)
+		}
+		if overridesCollation {
+			optionFile.SetOptionValue("", "default-collation", s.Collation)
+		}
+	}
+	schemaDir, err := parentDir.CreateSubdir(name, optionFile)
+	if err != nil {
+		return nil, NewExitValue(CodeCantCreate, "Unable to use directory %s for schema %s: %s", path.Join(parentDir.Path, name), s.Name, err)
+	}
+	return schemaDir, nil
+}
+
+// populateTableFiles writes a *.sql file to schemaDir for each table in s.
+func populateTableFiles(s *tengo.Schema, schemaDir *Dir) error {
 	tables, err := s.Tables()
 	if err != nil {
 		return fmt.Errorf("Cannot obtain table information for %s: %s", s.Name, err)
 	}
 	for _, t := range tables {
+		if isSoftDropped(t.Name) {
+			continue
+		}
 		createStmt := t.CreateStatement()
 
 		// Special handling for auto-increment tables: strip next-auto-inc value,
@@ -223,7 +360,7 @@ func PopulateSchemaDir(s *tengo.Schema, parentDir *Dir, makeSubdir bool) error {
 		if length, err = sf.Write(); err != nil {
 			return NewExitValue(CodeCantCreate, "Unable to write to %s: %s", sf.Path(), err)
 		}
-		log.Infof("Wrote %s (%d bytes)", sf.Path(), length)
+		log.Infof("%s %s (%d bytes)", schemaDir.fsVerb("Wrote", "Would write"), sf.Path(), length)
 	}
 	os.Stderr.WriteString("\n")
 	return nil
@@ -59,10 +59,7 @@ func LintHandler(cfg *mycli.Config) error {
 
 		tables, _ := t.SchemaFromDir.Tables() // can ignore error since table list already guaranteed to be cached
 		for _, table := range tables {
-			sf := SQLFile{
-				Dir:      t.Dir,
-				FileName: fmt.Sprintf("%s.sql", table.Name),
-			}
+			sf := t.SQLFileForTable(table.Name)
 			if _, err := sf.Read(); err != nil {
 				return err
 			}
@@ -26,6 +26,7 @@ top of the file. If no environment name is supplied, the default is
 	cmd := mycli.NewCommand("pull", summary, desc, PullHandler)
 	cmd.AddOption(mycli.BoolOption("include-auto-inc", 0, false, "Include starting auto-inc values in new table files, and update in existing files"))
 	cmd.AddOption(mycli.BoolOption("normalize", 0, true, "Reformat *.sql files to match SHOW CREATE TABLE"))
+	cmd.AddOption(mycli.BoolOption("dry-run", 0, false, "Output file changes that would be made, but don't make them"))
 	cmd.AddArg("environment", "production", false)
 	CommandSuite.AddSubCommand(cmd)
 }
@@ -55,7 +56,7 @@ func PullHandler(cfg *mycli.Config) error {
 			if err := t.Dir.Delete(); err != nil {
 				return fmt.Errorf("Unable to delete directory %s: %s", t.Dir, err)
 			}
-			log.Infof("Deleted directory %s -- schema no longer exists\n", t.Dir)
+			log.Infof("%s directory %s -- schema no longer exists\n", t.Dir.fsVerb("Deleted", "Would delete"), t.Dir)
 			continue
 		}
 
@@ -63,6 +64,7 @@ func PullHandler(cfg *mycli.Config) error {
 		if err != nil {
 			return err
 		}
+		filterSoftDropped(diff)
 
 		// Handle changes in schema's default character set and/or collation by
 		// persisting changes to the dir's option file. Errors here are just surfaced
@@ -85,10 +87,10 @@ func PullHandler(cfg *mycli.Config) error {
 					} else {
 						optionFile.UnsetOptionValue("", "default-collation")
 					}
-					if err = optionFile.Write(true); err != nil {
+					if err = t.Dir.rewriteOptionFile(optionFile); err != nil {
 						log.Warnf("Unable to update character set and/or collation for %s: %s", optionFile.Path(), err)
 					} else {
-						log.Infof("Wrote %s -- updated schema-level default-character-set and default-collation", optionFile.Path())
+						log.Infof("%s %s -- updated schema-level default-character-set and default-collation", t.Dir.fsVerb("Wrote", "Would write"), optionFile.Path())
 					}
 				} else {
 					log.Warnf("Unable to update character set and/or collation for %s: %s", optionFile.Path(), err)
@@ -116,31 +118,24 @@ func PullHandler(cfg *mycli.Config) error {
 			}
 			switch td := td.(type) {
 			case tengo.CreateTable:
-				sf := SQLFile{
-					Dir:      t.Dir,
-					FileName: fmt.Sprintf("%s.sql", td.Table.Name),
-					Contents: stmt,
-				}
+				sf := t.SQLFileForTable(td.Table.Name)
+				sf.Contents = stmt
 				if length, err := sf.Write(); err != nil {
 					return fmt.Errorf("Unable to write to %s: %s", sf.Path(), err)
 				} else if _, hadErr := t.SQLFileErrors[sf.Path()]; hadErr {
 					// SQL files with syntax errors will result in tengo.CreateTable since
 					// the temp schema will be missing the table, however we can detect this
 					// scenario by looking in the Target's SQLFileErrors
-					log.Infof("Wrote %s (%d bytes) -- updated file to replace invalid SQL", sf.Path(), length)
+					log.Infof("%s %s (%d bytes) -- updated file to replace invalid SQL", t.Dir.fsVerb("Wrote", "Would write"), sf.Path(), length)
 				} else {
-					log.Infof("Wrote %s (%d bytes) -- new table", sf.Path(), length)
+					log.Infof("%s %s (%d bytes) -- new table", t.Dir.fsVerb("Wrote", "Would write"), sf.Path(), length)
 				}
 			case tengo.DropTable:
-				table := td.Table
-				sf := SQLFile{
-					Dir:      t.Dir,
-					FileName: fmt.Sprintf("%s.sql", table.Name),
-				}
+				sf := t.SQLFileForTable(td.Table.Name)
 				if err := sf.Delete(); err != nil {
 					return fmt.Errorf("Unable to delete %s: %s", sf.Path(), err)
 				}
-				log.Infof("Deleted %s -- table no longer exists", sf.Path())
+				log.Infof("%s %s -- table no longer exists", t.Dir.fsVerb("Deleted", "Would delete"), sf.Path())
 			case tengo.AlterTable:
 				// skip if mods caused the diff to be a no-op
 				if stmt == "" {
@@ -151,16 +146,13 @@ func PullHandler(cfg *mycli.Config) error {
 				if err != nil {
 					return err
 				}
-				sf := SQLFile{
-					Dir:      t.Dir,
-					FileName: fmt.Sprintf("%s.sql", table.Name),
-					Contents: createStmt,
-				}
+				sf := t.SQLFileForTable(table.Name)
+				sf.Contents = createStmt
 				var length int
 				if length, err = sf.Write(); err != nil {
 					return fmt.Errorf("Unable to write to %s: %s", sf.Path(), err)
 				}
-				log.Infof("Wrote %s (%d bytes) -- updated file to reflect table alterations", sf.Path(), length)
+				log.Infof("%s %s (%d bytes) -- updated file to reflect table alterations", t.Dir.fsVerb("Wrote", "Would write"), sf.Path(), length)
 			case tengo.RenameTable:
 				return fmt.Errorf("Table renames not yet supported")
 			default:
@@ -172,24 +164,18 @@ func PullHandler(cfg *mycli.Config) error {
 		// updated. Handle same as AlterTable case, since created/dropped tables don't
 		// ever end up in UnsupportedTables since they don't do a diff operation.
 		for _, table := range diff.UnsupportedTables {
-			sf := SQLFile{
-				Dir:      t.Dir,
-				FileName: fmt.Sprintf("%s.sql", table.Name),
-				Contents: table.CreateStatement(),
-			}
+			sf := t.SQLFileForTable(table.Name)
+			sf.Contents = table.CreateStatement()
 			var length int
 			if length, err = sf.Write(); err != nil {
 				return fmt.Errorf("Unable to write to %s: %s", sf.Path(), err)
 			}
-			log.Infof("Wrote %s (%d bytes) -- updated file to reflect table alterations", sf.Path(), length)
+			log.Infof("%s %s (%d bytes) -- updated file to reflect table alterations", t.Dir.fsVerb("Wrote", "Would write"), sf.Path(), length)
 		}
 
 		if dir.Config.GetBool("normalize") {
 			for _, table := range diff.SameTables {
-				sf := SQLFile{
-					Dir:      t.Dir,
-					FileName: fmt.Sprintf("%s.sql", table.Name),
-				}
+				sf := t.SQLFileForTable(table.Name)
 				if _, err := sf.Read(); err != nil {
 					return err
 				}
@@ -202,7 +188,7 @@ func PullHandler(cfg *mycli.Config) error {
 					if length, err = sf.Write(); err != nil {
 						return fmt.Errorf("Unable to write to %s: %s", sf.Path(), err)
 					}
-					log.Infof("Wrote %s (%d bytes) -- updated file to normalize format", sf.Path(), length)
+					log.Infof("%s %s (%d bytes) -- updated file to normalize format", t.Dir.fsVerb("Wrote", "Would write"), sf.Path(), length)
 				}
 			}
 		}
@@ -215,6 +201,9 @@ func PullHandler(cfg *mycli.Config) error {
 	}
 
 	if errCount == 0 {
+		if dir.DryRun() && dryRunChanges > 0 {
+			return NewExitValue(CodeDifferencesFound, "")
+		}
 		return nil
 	}
 	var plural string
@@ -271,6 +260,22 @@ func findNewSchemas(dir *Dir) error {
 				return err
 			}
 			for _, s := range schemas {
+				// Skip the soft-drop-schema, as well as schemas that don't match
+				// schema-name-template, if one is in use, or are filtered out by
+				// include-schemas or exclude-schemas
+				if isSoftDropSchemaName(dir.Config, s.Name) {
+					continue
+				}
+				if _, ok, err := dir.LogicalSchemaName(s.Name); err != nil {
+					return err
+				} else if !ok {
+					continue
+				}
+				if included, err := dir.SchemaIncluded(s.Name); err != nil {
+					return err
+				} else if !included {
+					continue
+				}
 				if !subdirHasSchema[s.Name] {
 					// use same logic from init command
 					if err := PopulateSchemaDir(s, dir, true); err != nil {
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/skeema/mycli"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	summary := "Permanently drop tables that were previously soft-dropped"
+	desc := `Permanently drops tables that were previously soft-dropped by ` + "`" + `skeema push` + "`" + `
+with the soft-drop option enabled. Only tables that were soft-dropped longer ago
+than the duration supplied by --older-than are dropped.
+
+You may optionally pass an environment name as a CLI option. This will affect
+which section of .skeema config files is used for determining which database
+instances to purge. For example, running ` + "`" + `skeema purge-dropped staging` + "`" + ` will
+apply config directives from the [staging] section of config files, as well as
+any sectionless directives at the top of the file. If no environment name is
+supplied, the default is "production".`
+
+	cmd := mycli.NewCommand("purge-dropped", summary, desc, PurgeDroppedHandler)
+	cmd.AddOption(mycli.StringOption("older-than", 0, "", `Only drop tables soft-dropped longer ago than this duration (e.g. "7d" or "36h")`))
+	cmd.AddOption(mycli.BoolOption("dry-run", 0, false, "Output tables that would be dropped, but don't drop them"))
+	cmd.AddArg("environment", "production", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// PurgeDroppedHandler is the handler method for `skeema purge-dropped`
+func PurgeDroppedHandler(cfg *mycli.Config) error {
+	AddGlobalConfigFiles(cfg)
+	dir, err := NewDir(".", cfg)
+	if err != nil {
+		return err
+	}
+	if !dir.Config.Changed("older-than") {
+		return NewExitValue(CodeBadUsage, "purge-dropped requires --older-than option")
+	}
+	olderThan, err := parseAge(dir.Config.Get("older-than"))
+	if err != nil {
+		return NewExitValue(CodeBadConfig, "Option older-than: %s", err)
+	}
+	cutoff := time.Now().Add(-olderThan)
+	dryRun := dir.Config.GetBool("dry-run")
+
+	var errCount, dropCount int
+	for tg := range dir.TargetGroups(false, false) {
+		for _, t := range tg {
+			if t.Err != nil {
+				logTargetError(t)
+				errCount++
+				continue
+			}
+			tables, err := findSoftDropped(t)
+			if err != nil {
+				log.Errorf("Unable to find soft-dropped tables on %s: %s", t.Instance, err)
+				errCount++
+				continue
+			}
+			for _, sdt := range tables {
+				if sdt.DroppedAt.After(cutoff) {
+					log.Debugf("Keeping %s.%s on %s: soft-dropped at %s", sdt.SchemaName, sdt.Name, t.Instance, sdt.DroppedAt)
+					continue
+				}
+				stmt := fmt.Sprintf("DROP TABLE %s.%s", tengo.EscapeIdentifier(sdt.SchemaName), tengo.EscapeIdentifier(sdt.Name))
+				fmt.Printf("%s;\n", stmt)
+				dropCount++
+				if dryRun {
+					continue
+				}
+				db, err := t.Instance.Connect(sdt.SchemaName, "")
+				if err == nil {
+					_, err = db.Exec(stmt)
+				}
+				if err != nil {
+					log.Errorf("Error running DDL on %s: %s", t.Instance, err)
+					errCount++
+				}
+			}
+		}
+	}
+	os.Stderr.WriteString("\n")
+
+	if errCount > 0 {
+		return NewExitValue(CodePartialError, "Skipped %d operation%s due to error%s", errCount, pluralS(errCount), pluralS(errCount))
+	}
+	if dryRun && dropCount > 0 {
+		return NewExitValue(CodeDifferencesFound, "")
+	}
+	return nil
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -32,11 +35,22 @@ top of the file. If no environment name is supplied, the default is
 	cmd.AddOption(mycli.BoolOption("brief", 'q', false, "<overridden by diff command>").Hidden())
 	cmd.AddOption(mycli.StringOption("alter-wrapper", 'x', "", "External bin to shell out to for ALTER TABLE; see manual for template vars"))
 	cmd.AddOption(mycli.StringOption("alter-wrapper-min-size", 0, "0", "Ignore --alter-wrapper for tables smaller than this size in bytes"))
+	cmd.AddOption(mycli.BoolOption("alter-wrapper-blocking-only", 0, false, "Ignore --alter-wrapper for ALTER TABLEs predicted to not block writes"))
+	cmd.AddOption(mycli.BoolOption("predict-algorithm", 0, false, "Annotate each ALTER TABLE with its predicted algorithm and locking behavior"))
 	cmd.AddOption(mycli.StringOption("alter-lock", 0, "", `Apply a LOCK clause to all ALTER TABLEs (valid values: "NONE", "SHARED", "EXCLUSIVE")`))
 	cmd.AddOption(mycli.StringOption("alter-algorithm", 0, "", `Apply an ALGORITHM clause to all ALTER TABLEs (valid values: "INPLACE", "COPY")`))
 	cmd.AddOption(mycli.StringOption("ddl-wrapper", 'X', "", "Like --alter-wrapper, but applies to all DDL types (CREATE, DROP, ALTER)"))
 	cmd.AddOption(mycli.StringOption("safe-below-size", 0, "0", "Always permit destructive operations for tables below this size in bytes"))
+	cmd.AddOption(mycli.BoolOption("soft-drop", 0, false, "Rename tables with a timestamped prefix instead of dropping them"))
+	cmd.AddOption(mycli.BoolOption("check-data", 0, false, "Permit unsafe column modifications if querying existing data proves no values would be lost"))
+	cmd.AddOption(mycli.StringOption("check-data-max-rows", 0, "1000000", "Skip --check-data for tables with more than this many rows"))
+	cmd.AddOption(mycli.StringOption("check-data-timeout", 0, "5", "Maximum seconds permitted for each --check-data query"))
 	cmd.AddOption(mycli.StringOption("concurrent-instances", 'c', "1", "Perform operations on this number of instances concurrently"))
+	cmd.AddOption(mycli.StringOption("canary", 0, "0", "Push to this many targets (or percentage, e.g. \"10%\") first, and confirm them before pushing the rest"))
+	cmd.AddOption(mycli.StringOption("replica-push", 0, "refuse", `How to handle hosts that are read-only replicas (valid values: "refuse", "redirect", "allow")`))
+	cmd.AddOption(mycli.BoolOption("check-replicas", 0, false, "After pushing, wait for replicas to catch up and confirm their schemas match the filesystem"))
+	cmd.AddOption(mycli.StringOption("replica-hosts", 0, "", "Comma-separated list of replica host:port to check, instead of auto-discovering replicas"))
+	cmd.AddOption(mycli.StringOption("replica-wait-timeout", 0, "60", "Maximum seconds to wait for each replica to catch up for --check-replicas"))
 	cmd.AddArg("environment", "production", false)
 	CommandSuite.AddSubCommand(cmd)
 	clonePushOptionsToDiff()
@@ -44,8 +58,8 @@ top of the file. If no environment name is supplied, the default is
 
 // sharedPushState stores and manages state shared between multiple push workers
 type sharedPushState struct {
-	targetGroups       <-chan TargetGroup
 	dryRun             bool
+	haltOnError        bool // if true, workers stop after any error or unsupported table, not just fatal errors
 	briefOutput        bool
 	errCount           int
 	diffCount          int
@@ -74,24 +88,34 @@ func PushHandler(cfg *mycli.Config) error {
 		return err
 	}
 
+	// Canary mode only makes sense when actually running DDL, so it is ignored
+	// for dry-run / diff. Its value is validated here, before any work is done.
+	canarySpec := dir.Config.Get("canary")
+	useCanary := dir.Config.Changed("canary") && !cfg.GetBool("dry-run")
+	if useCanary {
+		if _, err := canaryTargetCount(canarySpec, 1); err != nil {
+			return NewExitValue(CodeBadConfig, "%s", err)
+		}
+	}
+
+	sps := &sharedPushState{
+		dryRun:      cfg.GetBool("dry-run"),
+		briefOutput: cfg.GetBool("brief") && cfg.GetBool("dry-run"),
+		Mutex:       new(sync.Mutex),
+		WaitGroup:   new(sync.WaitGroup),
+	}
+
 	// The 2nd param of dir.TargetGroups indicates that SQLFile errors are to be
 	// treated as fatal. This is required for push and diff. Otherwise, a file with
 	// invalid CREATE TABLE SQL would lead to a table being missing in the temp
 	// schema, which would confuse the logic that diffs schemas.
-	sps := &sharedPushState{
-		targetGroups: dir.TargetGroups(cfg.GetBool("first-only"), true),
-		dryRun:       cfg.GetBool("dry-run"),
-		briefOutput:  cfg.GetBool("brief") && cfg.GetBool("dry-run"),
-		Mutex:        new(sync.Mutex),
-		WaitGroup:    new(sync.WaitGroup),
-	}
-
-	for n := 0; n < workerCount; n++ {
-		sps.Add(1) // increment the waitgroup
-		go pushWorker(sps)
+	targetGroups := dir.TargetGroups(cfg.GetBool("first-only"), true)
+	if useCanary {
+		sps.pushWithCanary(targetGroups, canarySpec, workerCount)
+	} else {
+		sps.runWorkers(targetGroups, workerCount)
 	}
 
-	sps.Wait()
 	if sps.fatalError != nil {
 		return sps.fatalError
 	}
@@ -118,26 +142,113 @@ func PushHandler(cfg *mycli.Config) error {
 	return NewExitValue(code, "Skipped %d operation%s due to %s%s", sps.errCount+sps.unsupportedCount, plural, reason, plural)
 }
 
-func pushWorker(sps *sharedPushState) {
+// runWorkers starts workerCount push workers, which consume TargetGroups from
+// targetGroups until the channel is closed. It blocks until all workers have
+// completed.
+func (sps *sharedPushState) runWorkers(targetGroups <-chan TargetGroup, workerCount int) {
+	for n := 0; n < workerCount; n++ {
+		sps.Add(1) // increment the waitgroup
+		go pushWorker(sps, targetGroups)
+	}
+	sps.Wait()
+}
+
+// pushWithCanary first pushes to a subset of targets, as specified by
+// canarySpec. Once that completes without error, each canary target's schema is
+// re-introspected to confirm it now matches the filesystem. Only then are the
+// remaining targets pushed, in batches of workerCount instances at a time.
+// Any error, or any table with an unsupported diff, halts the entire operation.
+func (sps *sharedPushState) pushWithCanary(targetGroups <-chan TargetGroup, canarySpec string, workerCount int) {
+	sps.haltOnError = true
+
+	// Gather all Targets, in a deterministic order. If any could not be
+	// prepared, refuse to push anything at all.
+	var targets []*Target
+	var errTargets int
+	for tg := range targetGroups {
+		for _, t := range tg {
+			if t.Err != nil {
+				logTargetError(t)
+				errTargets++
+			} else {
+				targets = append(targets, t)
+			}
+		}
+	}
+	if errTargets > 0 {
+		sps.incrementErrCount(errTargets)
+		log.Errorf("Canary push aborted due to errors; no changes were pushed to any of %d other targets", len(targets))
+		return
+	}
+	sort.Sort(targetsByInstanceAndSchema(targets))
+
+	canaryCount, _ := canaryTargetCount(canarySpec, len(targets)) // already validated by caller
+	if canaryCount == 0 {
+		sps.runWorkers(targetGroupChannel(targets), workerCount)
+		return
+	}
+	canaries, remaining := targets[:canaryCount], targets[canaryCount:]
+
+	log.Infof("Pushing to %d canary target%s before remaining %d target%s", len(canaries), pluralS(len(canaries)), len(remaining), pluralS(len(remaining)))
+	sps.runWorkers(targetGroupChannel(canaries), workerCount)
+	if sps.halted() {
+		log.Errorf("Canary push failed or found unsupported tables; skipping remaining %d target%s", len(remaining), pluralS(len(remaining)))
+		return
+	}
+	for _, t := range canaries {
+		if err := t.confirmPushed(); err != nil {
+			sps.setFatalError(fmt.Errorf("Canary verification failed for %s %s: %s\nSkipped remaining %d target%s", t.Instance, t.SchemaFromDir.Name, err, len(remaining), pluralS(len(remaining))))
+			return
+		}
+		log.Infof("%s %s: canary verified, schema matches %s/*.sql", t.Instance, t.SchemaFromDir.Name, t.Dir)
+	}
+
+	// Push the rest in batches, with each batch containing up to workerCount
+	// instances. Wait for each batch to complete before beginning the next.
+	tgm := NewTargetGroupMap()
+	for _, t := range remaining {
+		tgm.Add(t)
+	}
+	keys := make([]string, 0, len(tgm))
+	for key := range tgm {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for len(keys) > 0 {
+		batchSize := workerCount
+		if batchSize > len(keys) {
+			batchSize = len(keys)
+		}
+		batch := make(chan TargetGroup, batchSize)
+		for _, key := range keys[:batchSize] {
+			batch <- tgm[key]
+		}
+		close(batch)
+		keys = keys[batchSize:]
+		sps.runWorkers(batch, workerCount)
+		if sps.halted() {
+			if len(keys) > 0 {
+				log.Errorf("Halting push due to previous error or unsupported table; skipping remaining %d instance%s", len(keys), pluralS(len(keys)))
+			}
+			return
+		}
+	}
+}
+
+func pushWorker(sps *sharedPushState, targetGroups <-chan TargetGroup) {
 	defer sps.Done()
 
 	mods := tengo.StatementModifiers{
 		NextAutoInc: tengo.NextAutoIncIfIncreased,
 	}
 
-	for tg := range sps.targetGroups { // consume a TargetGroup from the channel
+	for tg := range targetGroups { // consume a TargetGroup from the channel
 		for _, t := range tg { // iterate over each Target in the TargetGroup
-			if sps.fatalError != nil {
+			if sps.halted() {
 				return
 			}
 			if t.Err != nil {
-				if t.Instance == nil {
-					log.Errorf("Skipping %s: %s\n", t.Dir, t.Err)
-				} else if t.SchemaFromDir == nil {
-					log.Errorf("Skipping %s for %s: %s\n", t.Instance, t.Dir, t.Err)
-				} else {
-					log.Errorf("Skipping %s %s for %s: %s\n", t.Instance, t.SchemaFromDir.Name, t.Dir, t.Err)
-				}
+				logTargetError(t)
 				sps.incrementErrCount(1)
 				continue
 			}
@@ -160,7 +271,18 @@ func pushWorker(sps *sharedPushState) {
 				sps.setFatalError(err)
 				return
 			}
+			filterSoftDropped(diff)
 			var targetStmtCount int
+			var targetHadErr bool
+
+			// Verification must occur before any schema-level DDL is run, since
+			// creating or altering the schema modifies t.SchemaFromInstance
+			if t.Dir.Config.GetBool("verify") && (len(diff.TableDiffs) > 0 || diff.SchemaDDL != "") && !sps.briefOutput {
+				if err := t.verifyDiff(diff); err != nil {
+					sps.setFatalError(err)
+					return
+				}
+			}
 
 			if diff.SchemaDDL != "" {
 				sps.syncPrintf(t.Instance, "", "%s;\n", diff.SchemaDDL)
@@ -185,16 +307,10 @@ func pushWorker(sps *sharedPushState) {
 				}
 			}
 
-			if t.Dir.Config.GetBool("verify") && len(diff.TableDiffs) > 0 && !sps.briefOutput {
-				if err := t.verifyDiff(diff); err != nil {
-					sps.setFatalError(err)
-					return
-				}
-			}
-
 			// Set configuration-dependent statement modifiers here inside the Target
 			// loop, since the config for these may var per dir!
 			mods.AllowUnsafe = t.Dir.Config.GetBool("allow-unsafe") || sps.briefOutput
+			mods.Flavor = t.Instance.Flavor()
 			mods.AlgorithmClause, err = t.Dir.Config.GetEnum("alter-algorithm", "INPLACE", "COPY", "DEFAULT")
 			if err != nil {
 				sps.setFatalError(err)
@@ -217,6 +333,10 @@ func pushWorker(sps *sharedPushState) {
 				if ddl.Err != nil {
 					log.Errorf("%s. The affected DDL statement will be skipped. See --help for more information.", ddl.Err)
 					sps.incrementErrCount(1)
+					targetHadErr = true
+				}
+				if ddl.Prediction != nil && t.Dir.Config.GetBool("predict-algorithm") {
+					sps.syncPrintf(t.Instance, schemaName, "-- Predicted: %s\n", ddl.Prediction)
 				}
 				sps.syncPrintf(t.Instance, schemaName, "%s\n", ddl.String())
 				if !sps.dryRun && ddl.Err == nil && ddl.Execute() != nil {
@@ -226,6 +346,7 @@ func pushWorker(sps *sharedPushState) {
 						log.Warnf("Due to previous error, skipping %d additional statements on %s %s", skipCount-1, t.Instance, schemaName)
 					}
 					sps.incrementErrCount(skipCount)
+					targetHadErr = true
 					break
 				}
 			}
@@ -250,11 +371,83 @@ func pushWorker(sps *sharedPushState) {
 					verb = "push"
 				}
 				log.Infof("%s %s: %s complete\n", t.Instance, schemaName, verb)
+				if !sps.dryRun && !targetHadErr && t.Dir.Config.GetBool("check-replicas") {
+					sps.incrementErrCount(t.checkReplicas())
+				}
 			}
 		}
 	}
 }
 
+// logTargetError logs the reason why a Target with a non-nil Err is being
+// skipped.
+func logTargetError(t *Target) {
+	if t.Instance == nil {
+		log.Errorf("Skipping %s: %s\n", t.Dir, t.Err)
+	} else if t.SchemaFromDir == nil {
+		log.Errorf("Skipping %s for %s: %s\n", t.Instance, t.Dir, t.Err)
+	} else {
+		log.Errorf("Skipping %s %s for %s: %s\n", t.Instance, t.SchemaFromDir.Name, t.Dir, t.Err)
+	}
+}
+
+// canaryTargetCount parses the value of the canary option, which may be either
+// a number of targets or a percentage of targets, and returns how many of
+// total targets should be used as canaries. A nonzero percentage always
+// results in at least one canary.
+func canaryTargetCount(canarySpec string, total int) (int, error) {
+	canarySpec = strings.TrimSpace(canarySpec)
+	if canarySpec == "" {
+		return 0, nil
+	}
+	var count int
+	if strings.HasSuffix(canarySpec, "%") {
+		pct, err := strconv.ParseFloat(canarySpec[0:len(canarySpec)-1], 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return 0, fmt.Errorf("Option canary has invalid percentage \"%s\"", canarySpec)
+		}
+		count = int(math.Ceil(float64(total) * pct / 100))
+	} else {
+		var err error
+		if count, err = strconv.Atoi(canarySpec); err != nil || count < 0 {
+			return 0, fmt.Errorf("Option canary must be a non-negative integer or a percentage, instead found \"%s\"", canarySpec)
+		}
+	}
+	if count > total {
+		count = total
+	}
+	return count, nil
+}
+
+// targetGroupChannel arranges targets into TargetGroups, and returns a closed
+// buffered channel containing those TargetGroups.
+func targetGroupChannel(targets []*Target) <-chan TargetGroup {
+	tgm := NewTargetGroupMap()
+	for _, t := range targets {
+		tgm.Add(t)
+	}
+	groups := make(chan TargetGroup, len(tgm))
+	for _, tg := range tgm {
+		groups <- tg
+	}
+	close(groups)
+	return groups
+}
+
+func pluralS(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// halted returns true if workers should stop processing further targets.
+func (sps *sharedPushState) halted() bool {
+	sps.Lock()
+	defer sps.Unlock()
+	return sps.fatalError != nil || (sps.haltOnError && sps.errCount+sps.unsupportedCount > 0)
+}
+
 func (sps *sharedPushState) incrementErrCount(n int) {
 	sps.Lock()
 	sps.errCount += n
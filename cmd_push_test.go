@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestCanaryTargetCount(t *testing.T) {
+	assertCount := func(canarySpec string, total, expected int) {
+		actual, err := canaryTargetCount(canarySpec, total)
+		if err != nil {
+			t.Errorf("Unexpected error from canaryTargetCount(\"%s\", %d): %s", canarySpec, total, err)
+		} else if actual != expected {
+			t.Errorf("Expected canaryTargetCount(\"%s\", %d) to return %d, instead found %d", canarySpec, total, expected, actual)
+		}
+	}
+	assertCount("", 10, 0)
+	assertCount("0", 10, 0)
+	assertCount("3", 10, 3)
+	assertCount("30", 10, 10)
+	assertCount("0%", 10, 0)
+	assertCount("10%", 10, 1)
+	assertCount("10%", 3, 1)
+	assertCount("25%", 10, 3)
+	assertCount("100%", 7, 7)
+	assertCount(" 50% ", 4, 2)
+
+	expectError := []string{"-1", "abc", "5.5", "101%", "-5%", "%", "x%"}
+	for _, canarySpec := range expectError {
+		if _, err := canaryTargetCount(canarySpec, 10); err == nil {
+			t.Errorf("Did not get expected error from canaryTargetCount(\"%s\", 10)", canarySpec)
+		}
+	}
+}
+
+func TestSharedPushStateHalted(t *testing.T) {
+	sps := &sharedPushState{Mutex: new(sync.Mutex)}
+	if sps.halted() {
+		t.Error("Expected new sharedPushState to not be halted")
+	}
+	sps.incrementErrCount(1)
+	sps.incrementUnsupportedCount()
+	if sps.halted() {
+		t.Error("Expected errors and unsupported tables to not halt push without haltOnError")
+	}
+
+	sps = &sharedPushState{haltOnError: true, Mutex: new(sync.Mutex)}
+	sps.incrementUnsupportedCount()
+	if !sps.halted() {
+		t.Error("Expected unsupported table to halt push with haltOnError")
+	}
+	sps = &sharedPushState{haltOnError: true, Mutex: new(sync.Mutex)}
+	sps.incrementErrCount(1)
+	if !sps.halted() {
+		t.Error("Expected error to halt push with haltOnError")
+	}
+
+	sps = &sharedPushState{Mutex: new(sync.Mutex)}
+	sps.setFatalError(errors.New("fatal"))
+	if !sps.halted() {
+		t.Error("Expected fatal error to halt push, even without haltOnError")
+	}
+}
+
+func TestPushWithCanaryTargetErrors(t *testing.T) {
+	sps := &sharedPushState{
+		WaitGroup: new(sync.WaitGroup),
+		Mutex:     new(sync.Mutex),
+	}
+	tgm := NewTargetGroupMap()
+	tgm.AddDirError(&Dir{Path: "/tmp/one"}, errors.New("unable to connect"))
+	tgm.AddDirError(&Dir{Path: "/tmp/two"}, errors.New("unable to connect"))
+	targetGroups := make(chan TargetGroup, len(tgm))
+	for _, tg := range tgm {
+		targetGroups <- tg
+	}
+	close(targetGroups)
+	sps.pushWithCanary(targetGroups, "1", 2)
+	if sps.errCount != 2 {
+		t.Errorf("Expected errCount of 2, instead found %d", sps.errCount)
+	}
+	if !sps.haltOnError {
+		t.Error("Expected pushWithCanary to set haltOnError")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/skeema/mycli"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	summary := "Restore a table that was previously soft-dropped"
+	desc := `Restores a table that was previously soft-dropped by ` + "`" + `skeema push` + "`" + ` with
+the soft-drop option enabled, renaming it back to its original name. If the same
+table was soft-dropped multiple times, the most recently dropped copy is
+restored. The table's *.sql file is also rewritten, so that a subsequent push
+does not drop the table again.
+
+The restore is performed for every schema, in the current directory tree, that
+contains a soft-dropped copy of the named table. You may optionally pass an
+environment name as a CLI option, after the table name. This will affect which
+section of .skeema config files is used. If no environment name is supplied,
+the default is "production".`
+
+	cmd := mycli.NewCommand("restore-dropped", summary, desc, RestoreDroppedHandler)
+	cmd.AddOption(mycli.BoolOption("include-auto-inc", 0, false, "Include starting auto-inc value in the restored table's file"))
+	cmd.AddArg("table", "", true)
+	cmd.AddArg("environment", "production", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// RestoreDroppedHandler is the handler method for `skeema restore-dropped`
+func RestoreDroppedHandler(cfg *mycli.Config) error {
+	AddGlobalConfigFiles(cfg)
+	dir, err := NewDir(".", cfg)
+	if err != nil {
+		return err
+	}
+	tableName := cfg.Get("table")
+
+	var errCount, restoreCount int
+	for tg := range dir.TargetGroups(false, false) {
+		for _, t := range tg {
+			if t.Err != nil {
+				logTargetError(t)
+				errCount++
+				continue
+			}
+			if restored, err := restoreDroppedTable(t, tableName); err != nil {
+				log.Errorf("Unable to restore table %s on %s %s: %s", tableName, t.Instance, t.SchemaFromDir.Name, err)
+				errCount++
+			} else if restored {
+				restoreCount++
+			}
+		}
+	}
+	os.Stderr.WriteString("\n")
+
+	if errCount > 0 {
+		return NewExitValue(CodePartialError, "Skipped %d operation%s due to error%s", errCount, pluralS(errCount), pluralS(errCount))
+	} else if restoreCount == 0 {
+		return NewExitValue(CodeBadInput, "No soft-dropped copy of table %s found", tableName)
+	}
+	return nil
+}
+
+// restoreDroppedTable renames the most recently soft-dropped copy of the
+// named table, if any, back to its original name on the target. It then
+// writes the table's *.sql file in the target's dir. The returned bool
+// indicates whether a soft-dropped copy of the table was found and restored.
+func restoreDroppedTable(t *Target, tableName string) (bool, error) {
+	if t.SchemaFromInstance == nil {
+		return false, nil
+	}
+	tables, err := findSoftDropped(t)
+	if err != nil {
+		return false, err
+	}
+	var newest *softDroppedTable
+	for _, sdt := range tables {
+		if sdt.isCopyOf(tableName) && (newest == nil || sdt.DroppedAt.After(newest.DroppedAt)) {
+			newest = sdt
+		}
+	}
+	if newest == nil {
+		return false, nil
+	}
+	if t.SchemaFromInstance.HasTable(tableName) {
+		return false, fmt.Errorf("Table %s already exists", tableName)
+	}
+
+	schemaName := t.SchemaFromInstance.Name
+	stmt := fmt.Sprintf("RENAME TABLE %s.%s TO %s.%s",
+		tengo.EscapeIdentifier(newest.SchemaName), tengo.EscapeIdentifier(newest.Name),
+		tengo.EscapeIdentifier(schemaName), tengo.EscapeIdentifier(tableName))
+	db, err := t.Instance.Connect(schemaName, "")
+	if err != nil {
+		return false, err
+	}
+	if _, err := db.Exec(stmt); err != nil {
+		return false, err
+	}
+	log.Infof("Restored %s.%s on %s -- soft-dropped at %s", schemaName, tableName, t.Instance, newest.DroppedAt)
+
+	t.SchemaFromInstance.PurgeTableCache()
+	table, err := t.SchemaFromInstance.Table(tableName)
+	if err != nil {
+		return true, err
+	} else if table == nil {
+		return true, fmt.Errorf("Table %s not found after restore", tableName)
+	}
+	createStmt, err := t.Instance.ShowCreateTable(t.SchemaFromInstance, table)
+	if err != nil {
+		return true, err
+	}
+	if table.HasAutoIncrement() && !t.Dir.Config.GetBool("include-auto-inc") {
+		createStmt, _ = tengo.ParseCreateAutoInc(createStmt)
+	}
+	sf := SQLFile{
+		Dir:      t.Dir,
+		FileName: fmt.Sprintf("%s.sql", tableName),
+		Contents: createStmt,
+	}
+	length, err := sf.Write()
+	if err != nil {
+		return true, fmt.Errorf("Unable to write to %s: %s", sf.Path(), err)
+	}
+	log.Infof("Wrote %s (%d bytes) -- restored table", sf.Path(), length)
+	return true, nil
+}
@@ -28,17 +28,41 @@ func AddGlobalOptions(cmd *mycli.Command) {
 	cmd.AddOption(mycli.StringOption("schema", 0, "", "Database schema name").Hidden())
 	cmd.AddOption(mycli.StringOption("default-character-set", 0, "", "Schema-level default character set").Hidden())
 	cmd.AddOption(mycli.StringOption("default-collation", 0, "", "Schema-level default collation").Hidden())
+	cmd.AddOption(mycli.StringOption("schema-name-template", 0, "", "Template mapping schema option values to actual schema names; see manual for template vars").Hidden())
 
 	// Visible global options
 	cmd.AddOption(mycli.StringOption("user", 'u', "root", "Username to connect to database host"))
 	cmd.AddOption(mycli.StringOption("password", 'p', "<no password>", "Password for database user; supply with no value to prompt").ValueOptional())
+	cmd.AddOption(mycli.StringOption("login-path", 0, "", "Read user, password, host, port, and socket from this login path in ~/.mylogin.cnf"))
 	cmd.AddOption(mycli.StringOption("host-wrapper", 'H', "", "External bin to shell out to for host lookup; see manual for template vars"))
+	cmd.AddOption(mycli.StringOption("password-wrapper", 0, "", "External bin to shell out to for password lookup; see manual for template vars"))
+	cmd.AddOption(mycli.StringOption("include-schemas", 0, "", "Only consider schemas matching these comma-separated glob patterns, or /regex/, when discovering schemas on an instance"))
+	cmd.AddOption(mycli.StringOption("exclude-schemas", 0, "", "Ignore schemas matching these comma-separated glob patterns, or /regex/, when discovering schemas on an instance"))
+	cmd.AddOption(mycli.StringOption("soft-drop-schema", 0, "", "Schema that soft-dropped tables are moved into, if not left in place; never treated as a target"))
 	cmd.AddOption(mycli.StringOption("temp-schema", 't', "_skeema_tmp", "Name of temporary schema for intermediate operations, created and dropped each run unless --reuse-temp-schema"))
 	cmd.AddOption(mycli.StringOption("connect-options", 'o', "", "Comma-separated session options to set upon connecting to each database instance"))
+	cmd.AddOption(mycli.StringOption("ssh-host", 0, "", "Connect to database hosts through an SSH tunnel via this host"))
+	cmd.AddOption(mycli.StringOption("ssh-user", 0, "", "Username to connect to ssh-host, if different than the SSH client default"))
+	cmd.AddOption(mycli.StringOption("ssh-key", 0, "", "Path to private key file for connecting to ssh-host"))
+	cmd.AddOption(mycli.StringOption("ssl-mode", 0, "", `Security state of connections to database hosts: "DISABLED", "REQUIRED", "VERIFY_CA", or "VERIFY_IDENTITY"`))
+	cmd.AddOption(mycli.StringOption("ssl-ca", 0, "", "Path to file containing trusted SSL CA certificates, in PEM format"))
+	cmd.AddOption(mycli.StringOption("ssl-cert", 0, "", "Path to file containing client SSL certificate, in PEM format"))
+	cmd.AddOption(mycli.StringOption("ssl-key", 0, "", "Path to file containing client SSL private key, in PEM format"))
 	cmd.AddOption(mycli.BoolOption("reuse-temp-schema", 0, false, "Do not drop temp-schema when done"))
+	cmd.AddOption(mycli.BoolOption("unique-temp-schema", 0, false, "Append a unique per-process suffix to temp-schema, permitting concurrent runs"))
+	cmd.AddOption(mycli.StringOption("workspace-host", 0, "", "Separate database host to use for temp-schema operations, instead of each target host"))
+	cmd.AddOption(mycli.StringOption("workspace-port", 0, "3306", "Port to use for workspace-host"))
+	cmd.AddOption(mycli.StringOption("workspace-socket", 0, "/tmp/mysql.sock", "Absolute path to Unix socket file used if workspace-host is localhost"))
+	cmd.AddOption(mycli.StringOption("workspace-user", 0, "", "Username to connect to workspace-host, if different than user"))
+	cmd.AddOption(mycli.StringOption("workspace-password", 0, "", "Password to connect to workspace-host, if different than password"))
+	cmd.AddOption(mycli.StringOption("workspace-basedir", 0, "", "Start a temporary local mysqld from this basedir or binary path, and use it for temp-schema operations"))
 	cmd.AddOption(mycli.BoolOption("debug", 0, false, "Enable debug logging"))
 }
 
+// globalSectionParents maps environment section names to the name of the
+// section they inherit from, as declared in global option files.
+var globalSectionParents = make(map[string]string)
+
 // AddGlobalConfigFiles takes the mycli.Config generated from the CLI and adds
 // global option files as sources. It also handles special processing for a few
 // options. Generally, subcommand handlers should call AddGlobalConfigFiles at
@@ -49,6 +73,7 @@ func AddGlobalConfigFiles(cfg *mycli.Config) {
 	if home != "" {
 		globalFilePaths = append(globalFilePaths, path.Join(home, ".my.cnf"), path.Join(home, ".skeema"))
 	}
+	var globalFiles []*mycli.File
 	for _, path := range globalFilePaths {
 		f := mycli.NewFile(path)
 		if !f.Exists() {
@@ -60,19 +85,59 @@ func AddGlobalConfigFiles(cfg *mycli.Config) {
 		}
 		if strings.HasSuffix(path, ".my.cnf") {
 			f.IgnoreUnknownOptions = true
+		} else {
+			f.ExpandEnvVars = true
 		}
 		if err := f.Parse(cfg); err != nil {
 			log.Warnf("Ignoring global option file %s due to parse error: %s", f.Path(), err)
 			continue
 		}
-		if strings.HasSuffix(path, ".my.cnf") {
+		if !strings.HasSuffix(path, ".my.cnf") {
+			mergeSectionParents(globalSectionParents, f.SectionParents())
+		}
+		globalFiles = append(globalFiles, f)
+	}
+
+	// Sections are selected only after all global files have been parsed, since
+	// a section may inherit from a parent declared in a different file
+	environmentSections := sectionChain(cfg.Get("environment"), globalSectionParents)
+	for _, f := range globalFiles {
+		if strings.HasSuffix(f.Path(), ".my.cnf") {
 			_ = f.UseSection("skeema", "client") // safe to ignore error (doesn't matter if section doesn't exist)
 		} else {
-			_ = f.UseSection(cfg.Get("environment")) // safe to ignore error (doesn't matter if section doesn't exist)
+			_ = f.UseSection(environmentSections...) // safe to ignore error (doesn't matter if section doesn't exist)
 		}
+	}
 
+	// If a login path is configured (via CLI or any global option file), its
+	// values take precedence over ~/.my.cnf, but not over ~/.skeema
+	var loginPath mycli.OptionValuer
+	sources := make([]mycli.OptionValuer, len(globalFiles))
+	for n, f := range globalFiles {
+		sources[n] = f
+	}
+	if name := mycli.NewConfig(cfg.CLI, sources...).Get("login-path"); name != "" {
+		lps, err := NewLoginPathSource(LoginPathFile(), name)
+		if err != nil {
+			log.Warnf("Ignoring login-path %s: %s", name, err)
+		} else {
+			loginPath = lps
+		}
+	}
+	for _, f := range globalFiles {
+		if loginPath != nil && home != "" && f.Path() == path.Join(home, ".skeema") {
+			cfg.AddSource(loginPath)
+			loginPath = nil
+		}
 		cfg.AddSource(f)
 	}
+	if loginPath != nil {
+		cfg.AddSource(loginPath)
+	}
+
+	// SKEEMA_* environment variables override global option files, but not
+	// per-directory option files or the command-line
+	cfg.AddSource(EnvSource{})
 
 	// The host and schema options are special -- most commands only expect
 	// to find them when recursively crawling directory configs. So if these
@@ -105,11 +170,17 @@ func AddGlobalConfigFiles(cfg *mycli.Config) {
 // PromptPassword reads a password from STDIN without echoing the typed
 // characters. Requires that STDIN is a TTY.
 func PromptPassword() (string, error) {
+	return promptSecret("Enter password: ")
+}
+
+// promptSecret displays prompt, and then reads a value from STDIN without
+// echoing the typed characters. Requires that STDIN is a TTY.
+func promptSecret(prompt string) (string, error) {
 	stdin := int(syscall.Stdin)
 	if !terminal.IsTerminal(stdin) {
 		return "", errors.New("STDIN must be a TTY to read password")
 	}
-	fmt.Printf("Enter password: ")
+	fmt.Print(prompt)
 	bytePassword, err := terminal.ReadPassword(stdin)
 	if err != nil {
 		return "", err
@@ -231,3 +302,26 @@ func RealConnectOptions(connectOpts string) (string, error) {
 	}
 	return connectOpts, nil
 }
+
+// mergeSectionParents copies section inheritance declarations from src into
+// dest. Declarations in src take precedence, since src is expected to be an
+// option file lower in the config hierarchy than the ones previously merged.
+func mergeSectionParents(dest, src map[string]string) {
+	for name, parent := range src {
+		dest[name] = parent
+	}
+}
+
+// sectionChain returns a slice beginning with section, followed by its parent
+// section, that section's parent, and so on, according to parents. The chain
+// stops upon reaching a section without a parent, or a section already in the
+// chain (which would indicate an inheritance loop).
+func sectionChain(section string, parents map[string]string) []string {
+	chain := []string{section}
+	seen := map[string]bool{section: true}
+	for parent, ok := parents[section]; ok && !seen[parent]; parent, ok = parents[parent] {
+		chain = append(chain, parent)
+		seen[parent] = true
+	}
+	return chain
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+	"github.com/skeema/tengo"
+)
+
+// This file contains logic for querying a table's existing data, in order to
+// prove that a column modification considered unsafe by tengo will not
+// actually truncate or otherwise lose any data.
+
+// dataChecker runs data-aware safety checks against a single table.
+type dataChecker struct {
+	db        *sqlx.DB
+	table     *tengo.Table
+	tableName string
+}
+
+// newDataChecker returns a dataChecker for the table, or nil if the table's
+// data should not be checked: for example, if the table has too many rows, or
+// the server cannot enforce a query timeout.
+func newDataChecker(target *Target, table *tengo.Table) (*dataChecker, error) {
+	maxRows, err := target.Dir.Config.GetInt("check-data-max-rows")
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := target.Dir.Config.GetInt("check-data-timeout")
+	if err != nil {
+		return nil, err
+	}
+	if timeout < 1 {
+		return nil, fmt.Errorf("check-data-timeout must be at least 1 second")
+	}
+
+	rows, err := estimateTableRows(target.Instance, target.SchemaFromInstance, table)
+	if err != nil {
+		return nil, err
+	} else if rows > int64(maxRows) {
+		log.Debugf("Skipping check-data for table %s: approximately %d rows > check-data-max-rows=%d", table.Name, rows, maxRows)
+		return nil, nil
+	}
+
+	// Enforce the timeout server-side, so that a slow check does not continue
+	// running after Skeema gives up on it
+	flavor, err := instanceFlavor(target.Instance)
+	if err != nil {
+		return nil, err
+	}
+	var params string
+	if flavor.MariaDBAtLeast(10, 1, 1) {
+		params = fmt.Sprintf("max_statement_time=%d", timeout)
+	} else if flavor.MySQLAtLeast(5, 7, 8) {
+		params = fmt.Sprintf("max_execution_time=%d", timeout*1000)
+	} else {
+		log.Debugf("Skipping check-data for table %s: server version %s cannot enforce check-data-timeout", table.Name, flavor)
+		return nil, nil
+	}
+	db, err := target.Instance.Connect(target.SchemaFromInstance.Name, params)
+	if err != nil {
+		return nil, err
+	}
+	return &dataChecker{
+		db:        db,
+		table:     table,
+		tableName: tengo.EscapeIdentifier(table.Name),
+	}, nil
+}
+
+// estimateTableRows returns the approximate number of rows in the table,
+// based on information_schema.
+func estimateTableRows(instance *tengo.Instance, schema *tengo.Schema, table *tengo.Table) (int64, error) {
+	db, err := instance.Connect("information_schema", "")
+	if err != nil {
+		return 0, err
+	}
+	var rows sql.NullInt64
+	err = db.QueryRow(`
+		SELECT  table_rows
+		FROM    tables
+		WHERE   table_schema = ? and table_name = ?`,
+		schema.Name, table.Name).Scan(&rows)
+	return rows.Int64, err
+}
+
+// checkAlterData queries the data of the table affected by alter, to determine
+// whether all of its unsafe clauses can be proven to not lose any data. It
+// returns true if so. A non-nil error is returned if the data shows that the
+// ALTER would fail or silently modify existing values.
+func checkAlterData(alter tengo.AlterTable, target *Target) (bool, error) {
+	dc, err := newDataChecker(target, alter.Table)
+	if dc == nil || err != nil {
+		return false, err
+	}
+	allProven := true
+	for _, clause := range alter.Clauses {
+		mc, isModify := clause.(tengo.ModifyColumn)
+		if isModify && mc.OldColumn.Nullable && !mc.NewColumn.Nullable {
+			if err := dc.checkNoNulls(mc.NewColumn); err != nil {
+				return false, err
+			}
+		}
+		if !clause.Unsafe() || !allProven {
+			continue
+		}
+		if !isModify {
+			allProven = false
+			continue
+		}
+		proven, err := dc.checkModifyColumn(mc)
+		if err != nil {
+			log.Warnf("Unable to check data of column %s.%s: %s", alter.Table.Name, mc.NewColumn.Name, err)
+		}
+		allProven = proven && err == nil
+	}
+	return allProven, nil
+}
+
+// checkNoNulls returns an error if the column contains any NULL values, since
+// the column cannot be changed to NOT NULL without modifying these rows.
+func (dc *dataChecker) checkNoNulls(col *tengo.Column) error {
+	var count int64
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s IS NULL", dc.tableName, tengo.EscapeIdentifier(col.Name))
+	if err := dc.db.QueryRow(query).Scan(&count); err != nil {
+		log.Warnf("Unable to count NULL values in column %s.%s: %s", dc.table.Name, col.Name, err)
+		return nil
+	} else if count > 0 {
+		return fmt.Errorf("Column %s.%s cannot be made NOT NULL: %d rows contain NULL values", dc.table.Name, col.Name, count)
+	}
+	return nil
+}
+
+// checkModifyColumn returns true if the table's data proves that mc will not
+// truncate or otherwise modify any existing values. It returns false if the
+// column change is not of a type that can be checked, or if the data does not
+// fit the new column definition.
+func (dc *dataChecker) checkModifyColumn(mc tengo.ModifyColumn) (bool, error) {
+	if mc.OldColumn.CharSet != mc.NewColumn.CharSet {
+		return false, nil
+	}
+	oldType := strings.ToLower(mc.OldColumn.TypeInDB)
+	newType := strings.ToLower(mc.NewColumn.TypeInDB)
+	colName := tengo.EscapeIdentifier(mc.NewColumn.Name)
+
+	if maxLen, lenFunc := stringTypeMaxLength(newType); maxLen > 0 {
+		if oldLen, _ := stringTypeMaxLength(oldType); oldLen == 0 {
+			return false, nil
+		}
+		// CHAR and BINARY pad values, so converting to them from another type may
+		// modify values even if they fit
+		for _, padded := range []string{"char(", "binary("} {
+			if strings.HasPrefix(newType, padded) && !strings.HasPrefix(oldType, padded) {
+				return false, nil
+			}
+		}
+		var actual sql.NullInt64
+		query := fmt.Sprintf("SELECT MAX(%s(%s)) FROM %s", lenFunc, colName, dc.tableName)
+		if err := dc.db.QueryRow(query).Scan(&actual); err != nil {
+			return false, err
+		}
+		log.Debugf("Column %s.%s: max %s=%d, new type %s permits %d", dc.table.Name, mc.NewColumn.Name, lenFunc, actual.Int64, newType, maxLen)
+		return actual.Int64 <= maxLen, nil
+	}
+
+	if newMin, newMax := intTypeRange(newType); newMin != nil {
+		if oldMin, _ := intTypeRange(oldType); oldMin == nil {
+			return false, nil
+		}
+		var actualMin, actualMax sql.NullString
+		query := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", colName, colName, dc.tableName)
+		if err := dc.db.QueryRow(query).Scan(&actualMin, &actualMax); err != nil {
+			return false, err
+		}
+		if !actualMin.Valid { // no non-NULL values
+			return true, nil
+		}
+		min, ok1 := new(big.Int).SetString(actualMin.String, 10)
+		max, ok2 := new(big.Int).SetString(actualMax.String, 10)
+		if !ok1 || !ok2 {
+			return false, fmt.Errorf("Unable to parse MIN/MAX values %s/%s", actualMin.String, actualMax.String)
+		}
+		log.Debugf("Column %s.%s: min=%s max=%s, new type %s permits %s to %s", dc.table.Name, mc.NewColumn.Name, min, max, newType, newMin, newMax)
+		return min.Cmp(newMin) >= 0 && max.Cmp(newMax) <= 0, nil
+	}
+
+	isSet := strings.HasPrefix(newType, "set(")
+	if (isSet && strings.HasPrefix(oldType, "set(")) || (strings.HasPrefix(newType, "enum(") && strings.HasPrefix(oldType, "enum(")) {
+		allowed := make(map[string]bool)
+		for _, value := range enumTypeValues(mc.NewColumn.TypeInDB) {
+			allowed[value] = true
+		}
+		var inUse []sql.NullString
+		query := fmt.Sprintf("SELECT DISTINCT %s FROM %s", colName, dc.tableName)
+		if err := dc.db.Select(&inUse, query); err != nil {
+			return false, err
+		}
+		for _, value := range inUse {
+			if !value.Valid {
+				continue
+			}
+			members := []string{value.String}
+			if isSet {
+				members = strings.Split(value.String, ",")
+			}
+			for _, member := range members {
+				if member != "" && !allowed[member] {
+					log.Debugf("Column %s.%s: value %q in use, but not permitted by new type %s", dc.table.Name, mc.NewColumn.Name, member, newType)
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+var reStringTypeLength = regexp.MustCompile(`^(var)?(char|binary)\((\d+)\)`)
+
+// stringTypeMaxLength returns the maximum length of values in the supplied
+// string column type, along with the SQL function that should be used to
+// measure values against that length: CHAR_LENGTH for types with a length in
+// characters, or LENGTH for types with a length in bytes. If colType is not a
+// string type, 0 and an empty string are returned.
+func stringTypeMaxLength(colType string) (int64, string) {
+	if matches := reStringTypeLength.FindStringSubmatch(colType); matches != nil {
+		length, _ := strconv.ParseInt(matches[3], 10, 64)
+		if matches[2] == "char" {
+			return length, "CHAR_LENGTH"
+		}
+		return length, "LENGTH"
+	}
+	switch colType {
+	case "tinytext", "tinyblob":
+		return 255, "LENGTH"
+	case "text", "blob":
+		return 65535, "LENGTH"
+	case "mediumtext", "mediumblob":
+		return 16777215, "LENGTH"
+	case "longtext", "longblob":
+		return 4294967295, "LENGTH"
+	}
+	return 0, ""
+}
+
+var reIntType = regexp.MustCompile(`^(tiny|small|medium|big)?int(?:\(\d+\))?( unsigned)?`)
+
+// intTypeRange returns the minimum and maximum values permitted by the
+// supplied integer column type. If colType is not an integer type, nil values
+// are returned.
+func intTypeRange(colType string) (min, max *big.Int) {
+	matches := reIntType.FindStringSubmatch(colType)
+	if matches == nil {
+		return nil, nil
+	}
+	bits := map[string]uint{"tiny": 8, "small": 16, "medium": 24, "": 32, "big": 64}[matches[1]]
+	one := big.NewInt(1)
+	if matches[2] != "" { // unsigned
+		min = big.NewInt(0)
+		max = new(big.Int).Sub(new(big.Int).Lsh(one, bits), one)
+	} else {
+		max = new(big.Int).Sub(new(big.Int).Lsh(one, bits-1), one)
+		min = new(big.Int).Neg(new(big.Int).Add(max, one))
+	}
+	return min, max
+}
+
+// enumTypeValues returns the permitted values of the supplied ENUM or SET
+// column type.
+func enumTypeValues(colType string) []string {
+	start, end := strings.IndexByte(colType, '('), strings.LastIndexByte(colType, ')')
+	if start < 0 || end <= start {
+		return nil
+	}
+	var values []string
+	var current []byte
+	var inQuote bool
+	body := colType[start+1 : end]
+	for n := 0; n < len(body); n++ {
+		c := body[n]
+		if !inQuote {
+			if c == '\'' {
+				inQuote = true
+				current = current[:0]
+			}
+			continue
+		}
+		if c == '\'' && n+1 < len(body) && body[n+1] == '\'' { // escaped quote
+			current = append(current, c)
+			n++
+		} else if c == '\'' {
+			inQuote = false
+			values = append(values, string(current))
+		} else if c == '\\' && n+1 < len(body) {
+			current = append(current, body[n+1])
+			n++
+		} else {
+			current = append(current, c)
+		}
+	}
+	return values
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStringTypeMaxLength(t *testing.T) {
+	cases := map[string]struct {
+		length  int64
+		lenFunc string
+	}{
+		"varchar(100)":  {100, "CHAR_LENGTH"},
+		"char(10)":      {10, "CHAR_LENGTH"},
+		"varbinary(16)": {16, "LENGTH"},
+		"binary(16)":    {16, "LENGTH"},
+		"tinytext":      {255, "LENGTH"},
+		"mediumblob":    {16777215, "LENGTH"},
+		"int(10)":       {0, ""},
+		"enum('a')":     {0, ""},
+	}
+	for colType, expected := range cases {
+		length, lenFunc := stringTypeMaxLength(colType)
+		if length != expected.length || lenFunc != expected.lenFunc {
+			t.Errorf("Expected stringTypeMaxLength(\"%s\") to return %d,%s; instead found %d,%s", colType, expected.length, expected.lenFunc, length, lenFunc)
+		}
+	}
+}
+
+func TestIntTypeRange(t *testing.T) {
+	cases := map[string][2]string{
+		"tinyint(4)":           {"-128", "127"},
+		"tinyint(3) unsigned":  {"0", "255"},
+		"mediumint(9)":         {"-8388608", "8388607"},
+		"int(11)":              {"-2147483648", "2147483647"},
+		"int(10) unsigned":     {"0", "4294967295"},
+		"bigint(20)":           {"-9223372036854775808", "9223372036854775807"},
+		"bigint(20) unsigned":  {"0", "18446744073709551615"},
+		"smallint(5) unsigned": {"0", "65535"},
+	}
+	for colType, expected := range cases {
+		min, max := intTypeRange(colType)
+		if min == nil || max == nil {
+			t.Errorf("Unexpected nil return from intTypeRange(\"%s\")", colType)
+		} else if min.String() != expected[0] || max.String() != expected[1] {
+			t.Errorf("Expected intTypeRange(\"%s\") to return %s,%s; instead found %s,%s", colType, expected[0], expected[1], min, max)
+		}
+	}
+	for _, colType := range []string{"varchar(20)", "decimal(10,2)", "point"} {
+		if min, max := intTypeRange(colType); min != nil || max != nil {
+			t.Errorf("Expected intTypeRange(\"%s\") to return nils; instead found %s,%s", colType, min, max)
+		}
+	}
+}
+
+func TestEnumTypeValues(t *testing.T) {
+	cases := map[string][]string{
+		"enum('a','b','c')":            {"a", "b", "c"},
+		"set('x','y z')":               {"x", "y z"},
+		"enum('it''s','comma,inside')": {"it's", "comma,inside"},
+		"enum('')":                     {""},
+	}
+	for colType, expected := range cases {
+		if actual := enumTypeValues(colType); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Expected enumTypeValues(\"%s\") to return %v; instead found %v", colType, expected, actual)
+		}
+	}
+}
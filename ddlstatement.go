@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/skeema/tengo"
@@ -21,6 +22,11 @@ type DDLStatement struct {
 	// command)
 	Err error
 
+	// Prediction is the predicted algorithm and locking behavior of an ALTER
+	// TABLE. It is only populated if an option requiring it is enabled, and the
+	// server version could be determined.
+	Prediction *AlterPrediction
+
 	stmt     string
 	shellOut *ShellOut
 
@@ -57,22 +63,75 @@ func NewDDLStatement(diff tengo.TableDiff, mods tengo.StatementModifiers, target
 	}
 	ddl.setErr(err)
 
+	// Annotations in the table's *.sql file may override the dir's configuration
+	// for this table. An annotation explicitly disabling allow-unsafe also
+	// prevents safe-below-size and check-data from permitting unsafe changes.
+	config := target.Dir.Config
+	var forbidUnsafe bool
+	if annotations := target.TableAnnotations[tableName]; annotations != nil {
+		if annotations.Ignore() {
+			log.Debugf("Skipping table %s: ignored by skeema:ignore annotation", tableName)
+			return nil
+		}
+		config = config.Clone()
+		config.AddSource(annotations)
+		// Only adjust AllowUnsafe if the annotation changes the effective value,
+		// since callers may also enable it for other reasons (e.g. diff --brief)
+		if allowUnsafe := config.GetBool("allow-unsafe"); annotations.Has("allow-unsafe") && allowUnsafe != target.Dir.Config.GetBool("allow-unsafe") {
+			mods.AllowUnsafe = allowUnsafe
+		}
+		forbidUnsafe = annotations.Has("allow-unsafe") && !config.GetBool("allow-unsafe")
+		if annotations.Has("alter-algorithm") {
+			mods.AlgorithmClause, err = config.GetEnum("alter-algorithm", "INPLACE", "COPY", "DEFAULT")
+			ddl.setErr(err)
+		}
+		if annotations.Has("alter-lock") {
+			mods.LockClause, err = config.GetEnum("alter-lock", "NONE", "SHARED", "EXCLUSIVE", "DEFAULT")
+			ddl.setErr(err)
+		}
+	}
+
 	// If --safe-below-size option in use, enable additional statement modifier
 	// if the table's size is less than the supplied option value
-	safeBelowSize, err := target.Dir.Config.GetBytes("safe-below-size")
+	safeBelowSize, err := config.GetBytes("safe-below-size")
 	ddl.setErr(err)
-	if ddl.Err == nil && tableSize < int64(safeBelowSize) {
+	if ddl.Err == nil && !forbidUnsafe && tableSize < int64(safeBelowSize) {
 		mods.AllowUnsafe = true
 		log.Debugf("Allowing unsafe operations for table %s: size=%d < safe-below-size=%d", tableName, tableSize, safeBelowSize)
 	}
 
+	// If --check-data option in use, query the table's existing data to see if
+	// any unsafe column modifications can be proven to not lose data
+	if alter, isAlter := diff.(tengo.AlterTable); isAlter && ddl.Err == nil && !forbidUnsafe && tableSize > 0 && config.GetBool("check-data") {
+		proven, err := checkAlterData(alter, target)
+		ddl.setErr(err)
+		if proven && !mods.AllowUnsafe {
+			mods.AllowUnsafe = true
+			log.Debugf("Allowing unsafe operations for table %s: existing data fits new column definitions", tableName)
+		}
+	}
+
+	// Predict the ALTER's algorithm and locking behavior if needed. This must be
+	// done before any wrapper logic below can strip ALGORITHM or LOCK clauses.
+	blockingOnly := config.GetBool("alter-wrapper-blocking-only")
+	if alter, isAlter := diff.(tengo.AlterTable); isAlter && (blockingOnly || config.GetBool("predict-algorithm")) {
+		if flavor, err := instanceFlavor(target.Instance); err != nil {
+			log.Warnf("Unable to predict ALTER algorithm for table %s: %s", tableName, err)
+		} else {
+			prediction := PredictAlter(alter, flavor, mods)
+			ddl.Prediction = &prediction
+		}
+	}
+
 	// Options may indicate some/all DDL gets executed by shelling out to another program.
-	wrapper := target.Dir.Config.Get("ddl-wrapper")
-	if _, isAlter := diff.(tengo.AlterTable); isAlter && target.Dir.Config.Changed("alter-wrapper") {
-		minSize, err := target.Dir.Config.GetBytes("alter-wrapper-min-size")
+	wrapper := config.Get("ddl-wrapper")
+	if _, isAlter := diff.(tengo.AlterTable); isAlter && config.Changed("alter-wrapper") {
+		minSize, err := config.GetBytes("alter-wrapper-min-size")
 		ddl.setErr(err)
-		if tableSize >= int64(minSize) {
-			wrapper = target.Dir.Config.Get("alter-wrapper")
+		if blockingOnly && ddl.Prediction != nil && !ddl.Prediction.BlocksWrites {
+			log.Debugf("Skipping alter-wrapper for table %s: predicted %s", tableName, ddl.Prediction)
+		} else if tableSize >= int64(minSize) {
+			wrapper = config.Get("alter-wrapper")
 
 			// If alter-wrapper-min-size is set, and the table is big enough to use
 			// alter-wrapper, disable --alter-algorithm and --alter-lock. This allows
@@ -102,6 +161,16 @@ func NewDDLStatement(diff tengo.TableDiff, mods tengo.StatementModifiers, target
 		return nil
 	}
 
+	// If --soft-drop option in use, rename dropped tables instead of dropping
+	// them. This is only done if the DROP would have been permitted anyway.
+	if drop, isDrop := diff.(tengo.DropTable); isDrop && ddl.Err == nil && config.GetBool("soft-drop") {
+		ddl.stmt, err = softDropStatement(target, drop.Table, time.Now())
+		ddl.setErr(err)
+		if ddl.Err != nil {
+			ddl.stmt, _ = diff.Statement(mods)
+		}
+	}
+
 	// Apply wrapper if relevant
 	if wrapper != "" {
 		extras := map[string]string{
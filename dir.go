@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -19,9 +20,11 @@ import (
 
 // Dir represents a directory that Skeema is interacting with.
 type Dir struct {
-	Path    string
-	Config  *mycli.Config // Unified config including this dir's options file (and its parents' open files)
-	section string        // For options files, which section name to use, if any
+	Path                  string
+	Config                *mycli.Config     // Unified config including this dir's options file (and its parents' open files)
+	section               string            // For options files, which section name to use, if any
+	sectionParents        map[string]string // Section inheritance declared in global option files and the top-level .skeema file
+	hasAncestorOptionFile bool              // true if a parent dir's .skeema file is already included in Config
 }
 
 // NewDir returns a value representing a directory that Skeema may operate upon.
@@ -38,23 +41,35 @@ func NewDir(path string, baseConfig *mycli.Config) (*Dir, error) {
 	}
 
 	dir := &Dir{
-		Path:    path,
-		Config:  baseConfig.Clone(),
-		section: baseConfig.Get("environment"),
+		Path:           path,
+		Config:         baseConfig.Clone(),
+		section:        baseConfig.Get("environment"),
+		sectionParents: make(map[string]string),
 	}
+	mergeSectionParents(dir.sectionParents, globalSectionParents)
 
 	// Get slice of option files from root on down to this dir, in that order.
-	// Then parse and apply them to the config.
+	// Then parse them all, so that section inheritance is known before applying
+	// them to the config.
 	dirOptionFiles, err := dir.cascadingOptionFiles()
 	if err != nil {
 		return nil, err
 	}
-	for _, optionFile := range dirOptionFiles {
+	for n, optionFile := range dirOptionFiles {
 		err := optionFile.Parse(dir.Config)
 		if err != nil {
 			return nil, err
 		}
-		_ = optionFile.UseSection(dir.section) // we don't care if the section doesn't exist
+		if err := checkSectionParents(optionFile, n > 0); err != nil {
+			return nil, err
+		}
+		mergeSectionParents(dir.sectionParents, optionFile.SectionParents())
+		if optionFile.Dir != dir.Path {
+			dir.hasAncestorOptionFile = true
+		}
+	}
+	for _, optionFile := range dirOptionFiles {
+		_ = optionFile.UseSection(dir.Sections()...) // we don't care if the sections don't exist
 		dir.Config.AddSource(optionFile)
 	}
 
@@ -65,12 +80,21 @@ func (dir *Dir) String() string {
 	return dir.Path
 }
 
+// Sections returns the names of the option file sections used by this dir, in
+// order of precedence: the environment's section, followed by any sections it
+// inherits from.
+func (dir *Dir) Sections() []string {
+	return sectionChain(dir.section, dir.sectionParents)
+}
+
 // BaseName returns the name of the directory without the rest of its path.
 func (dir *Dir) BaseName() string {
 	return path.Base(dir.Path)
 }
 
-// CreateIfMissing creates the directory if it does not yet exist.
+// CreateIfMissing creates the directory if it does not yet exist. In dry-run
+// mode, the directory is not actually created, but the return value still
+// indicates whether it would have been.
 func (dir *Dir) CreateIfMissing() (created bool, err error) {
 	fi, err := os.Stat(dir.Path)
 	if err == nil {
@@ -82,6 +106,10 @@ func (dir *Dir) CreateIfMissing() (created bool, err error) {
 	if !os.IsNotExist(err) {
 		return false, fmt.Errorf("Unable to use directory %s: %s\n", dir.Path, err)
 	}
+	if dir.DryRun() {
+		dryRunChanges++
+		return true, nil
+	}
 	err = os.MkdirAll(dir.Path, 0777)
 	if err != nil {
 		return false, fmt.Errorf("Unable to create directory %s: %s\n", dir.Path, err)
@@ -96,8 +124,13 @@ func (dir *Dir) Exists() bool {
 	return (err == nil)
 }
 
-// Delete unlinks the directory and all files within.
+// Delete unlinks the directory and all files within. In dry-run mode, nothing
+// is removed.
 func (dir *Dir) Delete() error {
+	if dir.DryRun() {
+		dryRunChanges++
+		return nil
+	}
 	return os.RemoveAll(dir.Path)
 }
 
@@ -147,14 +180,10 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 		return nil, nil
 	}
 
-	// Before looping over hostnames, do a single lookup of user, password,
-	// connect-options, port, socket.
-	var userAndPass string
-	if !dir.Config.Changed("password") {
-		userAndPass = dir.Config.Get("user")
-	} else {
-		userAndPass = fmt.Sprintf("%s:%s", dir.Config.Get("user"), dir.Config.Get("password"))
-	}
+	// Before looping over hostnames, do a single lookup of user,
+	// connect-options, port, socket. (The password is looked up separately for
+	// each host, since password-wrapper may return a different one per host.)
+	user := dir.Config.Get("user")
 	params, err := dir.InstanceDefaultParams()
 	if err != nil {
 		return nil, fmt.Errorf("Invalid connection options: %s", err)
@@ -184,11 +213,19 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 	// For each hostname, construct a DSN and use it to create an Instance
 	var instances []*tengo.Instance
 	for _, host := range hosts {
-		var dsn string
+		var dsn, safeDSN string
 		thisPortValue := portValue
 		// TODO also support cloudsql DSNs
 		if host == "localhost" && (socketWasSupplied || !portWasSupplied) {
+			userAndPass, hasPassword, err := dir.userAndPass(user, host, thisPortValue)
+			if err != nil {
+				return nil, err
+			}
 			dsn = fmt.Sprintf("%s@unix(%s)/?%s", userAndPass, socketValue, params)
+			safeDSN = dsn
+			if hasPassword {
+				safeDSN = strings.Replace(dsn, userAndPass, fmt.Sprintf("%s:*****", user), 1)
+			}
 		} else {
 			splitHost, splitPort, err := tengo.SplitHostOptionalPort(host)
 			if err != nil {
@@ -201,21 +238,202 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 				host = splitHost
 				thisPortValue = splitPort
 			}
-			dsn = fmt.Sprintf("%s@tcp(%s:%d)/?%s", userAndPass, host, thisPortValue, params)
+			if dsn, safeDSN, err = dir.tcpDSN(user, host, thisPortValue, params); err != nil {
+				return nil, err
+			}
 		}
 		instance, err := tengo.NewInstance("mysql", dsn)
 		if err != nil || instance == nil {
-			if dir.Config.Changed("password") {
-				safeUserPass := fmt.Sprintf("%s:*****", dir.Config.Get("user"))
-				dsn = strings.Replace(dsn, userAndPass, safeUserPass, 1)
-			}
-			return nil, fmt.Errorf("Invalid connection information for %s (DSN=%s): %s", dir, dsn, err)
+			return nil, fmt.Errorf("Invalid connection information for %s (DSN=%s): %s", dir, safeDSN, err)
 		}
 		instances = append(instances, instance)
 	}
 	return instances, nil
 }
 
+// tcpDSN returns a DSN for connecting to host and port over TCP as user, with
+// the supplied params. The password, TLS settings, and SSH tunnel (if any) are
+// determined by dir's configuration. The second return value is the same DSN
+// with any password masked, for use in error messages.
+func (dir *Dir) tcpDSN(user, host string, port int, params string) (string, string, error) {
+	userAndPass, hasPassword, err := dir.userAndPass(user, host, port)
+	if err != nil {
+		return "", "", err
+	}
+	tlsValue, err := dir.TLSParam(host)
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid ssl options: %s", err)
+	}
+	// If an SSH host is configured, connect through a tunnel to host instead
+	if dir.Config.Changed("ssh-host") {
+		tunnel, err := dir.SSHTunnel(host, port)
+		if err != nil {
+			return "", "", fmt.Errorf("Unable to open SSH tunnel for %s: %s", dir, err)
+		}
+		host, port = "127.0.0.1", tunnel.localPort
+	}
+	dsn := fmt.Sprintf("%s@tcp(%s:%d)/?%s", userAndPass, host, port, addTLSParam(params, tlsValue))
+	if !hasPassword {
+		return dsn, dsn, nil
+	}
+	return dsn, strings.Replace(dsn, userAndPass, fmt.Sprintf("%s:*****", user), 1), nil
+}
+
+// RelatedInstance returns a tengo.Instance for connecting to a replica or
+// primary of one of the dir's instances, at the supplied host and port, using
+// the same connection configuration as the dir's own instances. The instance
+// is NOT checked for connectivity.
+func (dir *Dir) RelatedInstance(host string, port int) (*tengo.Instance, error) {
+	params, err := dir.InstanceDefaultParams()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid connection options: %s", err)
+	}
+	dsn, safeDSN, err := dir.tcpDSN(dir.Config.Get("user"), host, port, params)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := tengo.NewInstance("mysql", dsn)
+	if err != nil || instance == nil {
+		return nil, fmt.Errorf("Invalid connection information for %s (DSN=%s): %s", dir, safeDSN, err)
+	}
+	return instance, nil
+}
+
+// WorkspaceInstance returns a tengo.Instance for the workspace-host option,
+// which is a separate database instance used for all temp-schema operations.
+// If workspace-host is not set, nil is returned, indicating that temp-schema
+// operations should occur on each target's own instance. The user and
+// password default to the values of the user and password options, unless
+// overridden by workspace-user and workspace-password. The instance is NOT
+// checked for connectivity.
+//
+// Alternatively, if workspace-basedir is set, a temporary local mysqld is
+// started (if not already running) and returned.
+func (dir *Dir) WorkspaceInstance() (*tengo.Instance, error) {
+	if dir.Config.Changed("workspace-basedir") {
+		if dir.Config.Changed("workspace-host") {
+			return nil, fmt.Errorf("Options workspace-host and workspace-basedir cannot both be set")
+		}
+		params, err := dir.InstanceDefaultParams()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid connection options: %s", err)
+		}
+		return LocalWorkspaceInstance(dir.Config.Get("workspace-basedir"), params, 60*time.Second)
+	}
+	if !dir.Config.Changed("workspace-host") {
+		return nil, nil
+	}
+	user := dir.Config.Get("user")
+	if dir.Config.Changed("workspace-user") {
+		user = dir.Config.Get("workspace-user")
+	}
+	params, err := dir.InstanceDefaultParams()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid connection options: %s", err)
+	}
+
+	host := dir.Config.Get("workspace-host")
+	port := dir.Config.GetIntOrDefault("workspace-port")
+	useSocket := host == "localhost" && (dir.Config.Supplied("workspace-socket") || !dir.Config.Supplied("workspace-port"))
+	if !useSocket {
+		splitHost, splitPort, err := tengo.SplitHostOptionalPort(host)
+		if err != nil {
+			return nil, err
+		}
+		if splitPort > 0 {
+			host, port = splitHost, splitPort
+		}
+	}
+
+	var userAndPass string
+	var hasPassword bool
+	if dir.Config.Changed("workspace-password") {
+		userAndPass, hasPassword = fmt.Sprintf("%s:%s", user, dir.Config.Get("workspace-password")), true
+	} else if userAndPass, hasPassword, err = dir.userAndPass(user, host, port); err != nil {
+		return nil, err
+	}
+
+	var dsn string
+	if useSocket {
+		dsn = fmt.Sprintf("%s@unix(%s)/?%s", userAndPass, dir.Config.Get("workspace-socket"), params)
+	} else {
+		tlsValue, err := dir.TLSParam(host)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ssl options: %s", err)
+		}
+		dsn = fmt.Sprintf("%s@tcp(%s:%d)/?%s", userAndPass, host, port, addTLSParam(params, tlsValue))
+	}
+	instance, err := tengo.NewInstance("mysql", dsn)
+	if err != nil || instance == nil {
+		if hasPassword {
+			dsn = strings.Replace(dsn, userAndPass, fmt.Sprintf("%s:*****", user), 1)
+		}
+		return nil, fmt.Errorf("Invalid workspace connection information for %s (DSN=%s): %s", dir, dsn, err)
+	}
+	return instance, nil
+}
+
+var wrapperPasswords struct {
+	sync.Mutex
+	byCommand map[string]string
+}
+
+// userAndPass returns the user and password portion of a DSN for connecting to
+// the supplied host and port as user, along with a bool indicating whether a
+// password is included. If the password-wrapper option is set, it is run to
+// obtain the password; its output is cached for the lifetime of the process,
+// keyed by the interpolated command-line. Otherwise, the password option is
+// used, if set.
+func (dir *Dir) userAndPass(user, host string, port int) (string, bool, error) {
+	password, hasPassword, err := dir.password(user, host, port)
+	if err != nil || !hasPassword {
+		return user, false, err
+	}
+	return fmt.Sprintf("%s:%s", user, password), true, nil
+}
+
+// password returns the password for connecting to the supplied host and port
+// as user, along with a bool indicating whether a password is set. See
+// userAndPass for how the password is determined.
+func (dir *Dir) password(user, host string, port int) (string, bool, error) {
+	if !dir.Config.Changed("password-wrapper") {
+		if !dir.Config.Changed("password") {
+			return "", false, nil
+		}
+		return dir.Config.Get("password"), true, nil
+	}
+
+	// The password-wrapper cannot refer to its own output, so PASSWORD is blank
+	// when interpolating it
+	extras := map[string]string{
+		"HOST":     host,
+		"PORT":     strconv.Itoa(port),
+		"USER":     user,
+		"PASSWORD": "",
+	}
+	s, err := NewInterpolatedShellOut(dir.Config.Get("password-wrapper"), dir, extras)
+	if err != nil {
+		return "", false, err
+	}
+	wrapperPasswords.Lock()
+	defer wrapperPasswords.Unlock()
+	password, ok := wrapperPasswords.byCommand[s.Command]
+	if !ok {
+		// Note: intentionally not including the command's output in any error or
+		// log message, since it may contain the password
+		output, err := s.RunCapture()
+		if err != nil {
+			return "", false, fmt.Errorf("Error running password-wrapper for %s: %s", host, err)
+		}
+		password = strings.TrimRight(output, "\r\n")
+		if wrapperPasswords.byCommand == nil {
+			wrapperPasswords.byCommand = make(map[string]string)
+		}
+		wrapperPasswords.byCommand[s.Command] = password
+	}
+	return password, true, nil
+}
+
 // FirstInstance returns at most one tengo.Instance based on the directory's
 // configuration. If the config maps to multiple instances, only the first will
 // be returned. If the config maps to no instances, nil will be returned. The
@@ -265,10 +483,6 @@ func (dir *Dir) SchemaNames(instance *tengo.Instance) ([]string, error) {
 		return s.RunCaptureSplit()
 	}
 
-	if strings.ContainsAny(schemaValue, ",") {
-		return dir.Config.GetSlice("schema", ',', true), nil
-	}
-
 	if schemaValue == "*" {
 		// This automatically already filters out information_schema, performance_schema, sys, test, mysql
 		schemasByName, err := instance.SchemasByName()
@@ -277,12 +491,38 @@ func (dir *Dir) SchemaNames(instance *tengo.Instance) ([]string, error) {
 		}
 		schemaNames := make([]string, 0, len(schemasByName))
 		for name := range schemasByName {
-			schemaNames = append(schemaNames, name)
+			// Never include the temp schema, including any unique temp schemas from
+			// concurrent or interrupted runs, nor the soft-drop-schema
+			if isTempSchemaName(dir.Config, name) || isSoftDropSchemaName(dir.Config, name) {
+				continue
+			}
+			// If using schema-name-template, only include schemas matching it. Also
+			// apply include-schemas and exclude-schemas filters.
+			if _, ok, err := dir.LogicalSchemaName(name); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+			if included, err := dir.SchemaIncluded(name); err != nil {
+				return nil, err
+			} else if included {
+				schemaNames = append(schemaNames, name)
+			}
 		}
 		return schemaNames, nil
 	}
 
-	return []string{schemaValue}, nil
+	schemaNames := []string{schemaValue}
+	if strings.ContainsAny(schemaValue, ",") {
+		schemaNames = dir.Config.GetSlice("schema", ',', true)
+	}
+	for n, name := range schemaNames {
+		var err error
+		if schemaNames[n], err = dir.ActualSchemaName(name); err != nil {
+			return nil, err
+		}
+	}
+	return schemaNames, nil
 }
 
 // InstanceDefaultParams returns a param string for use in constructing a
@@ -382,9 +622,11 @@ func (dir *Dir) Subdirs() ([]*Dir, error) {
 	for _, fi := range fileInfos {
 		if fi.IsDir() {
 			subdir := &Dir{
-				Path:    path.Join(dir.Path, fi.Name()),
-				Config:  dir.Config.Clone(),
-				section: dir.section,
+				Path:                  path.Join(dir.Path, fi.Name()),
+				Config:                dir.Config.Clone(),
+				section:               dir.section,
+				sectionParents:        dir.copySectionParents(),
+				hasAncestorOptionFile: dir.hasAncestorOptionFile || dir.HasOptionFile(),
 			}
 			if subdir.HasOptionFile() {
 				f, err := subdir.OptionFile()
@@ -402,9 +644,11 @@ func (dir *Dir) Subdirs() ([]*Dir, error) {
 // CreateSubdir creates and returns a new subdir of the current dir.
 func (dir *Dir) CreateSubdir(name string, optionFile *mycli.File) (*Dir, error) {
 	subdir := &Dir{
-		Path:    path.Join(dir.Path, name),
-		Config:  dir.Config.Clone(),
-		section: dir.section,
+		Path:                  path.Join(dir.Path, name),
+		Config:                dir.Config.Clone(),
+		section:               dir.section,
+		sectionParents:        dir.copySectionParents(),
+		hasAncestorOptionFile: dir.hasAncestorOptionFile || dir.HasOptionFile(),
 	}
 
 	if created, err := subdir.CreateIfMissing(); err != nil {
@@ -422,13 +666,16 @@ func (dir *Dir) CreateSubdir(name string, optionFile *mycli.File) (*Dir, error)
 }
 
 // CreateOptionFile writes the supplied unwritten option file to this dir, and
-// then adds it as a source for this dir's configuration.
+// then adds it as a source for this dir's configuration. In dry-run mode, the
+// file's contents are displayed instead of being written.
 func (dir *Dir) CreateOptionFile(optionFile *mycli.File) error {
 	optionFile.Dir = dir.Path
-	if err := optionFile.Write(false); err != nil {
+	if dir.DryRun() {
+		showFileChange(optionFile.Path(), "", optionFile.Render())
+	} else if err := optionFile.Write(false); err != nil {
 		return fmt.Errorf("Unable to write to %s: %s", optionFile.Path(), err)
 	}
-	_ = optionFile.UseSection(dir.section)
+	_ = optionFile.UseSection(dir.Sections()...)
 	dir.Config.AddSource(optionFile)
 	return nil
 }
@@ -492,23 +739,39 @@ func (dir *Dir) Targets() []*Target {
 // cleans up the temp schema. Errors that occur along the way are handled and
 // tracked accordingly.
 //
-// The supplied instance will be used for temporary schema operations, and
-// will be stored in the returned Target, but may safely be changed to point
-// to a different instance as needed.
+// The supplied instance will be used for temporary schema operations, unless
+// the workspace-host option is set. The supplied instance will be stored in the
+// returned Target, but may safely be changed to point to a different instance
+// as needed.
 func (dir *Dir) TargetTemplate(instance *tengo.Instance) Target {
 	t := Target{
-		Dir:             dir,
-		Instance:        instance,
-		SQLFileErrors:   make(map[string]*SQLFile),
-		SQLFileWarnings: make([]error, 0),
-	}
-	tempSchemaName := dir.Config.Get("temp-schema")
+		Dir:              dir,
+		Instance:         instance,
+		SQLFileErrors:    make(map[string]*SQLFile),
+		SQLFileWarnings:  make([]error, 0),
+		TableAnnotations: make(map[string]TableAnnotations),
+		TableFiles:       make(map[string]string),
+	}
+	tempSchemaName := TempSchemaName(dir.Config)
 	sqlFiles, err := dir.SQLFiles()
 	if err != nil {
 		t.Err = fmt.Errorf("Unable to list SQL files in %s: %s", dir, err)
 		return t
 	}
 
+	// If a separate workspace instance is configured, perform all temp schema
+	// operations there instead
+	if t.Workspace, err = dir.WorkspaceInstance(); err != nil {
+		t.Err = err
+		return t
+	} else if t.Workspace != nil {
+		if ok, err := t.Workspace.CanConnect(); !ok {
+			t.Err = fmt.Errorf("Unable to connect to workspace %s for %s: %s", t.Workspace, dir, err)
+			return t
+		}
+		instance = t.Workspace
+	}
+
 	// TODO: want to skip binlogging for all temp schema actions, if super priv available
 	var tx *sql.Tx
 	if tx, err = t.lockTempSchema(30 * time.Second); err != nil {
@@ -555,6 +818,10 @@ func (dir *Dir) TargetTemplate(instance *tengo.Instance) Target {
 		for _, warning := range sf.Warnings {
 			t.SQLFileWarnings = append(t.SQLFileWarnings, warning)
 		}
+		t.TableFiles[sf.tableName] = sf.FileName
+		if len(sf.Annotations) > 0 {
+			t.TableAnnotations[sf.tableName] = sf.TableAnnotations()
+		}
 		_, err := db.Exec(sf.Contents)
 		if err != nil {
 			if tengo.IsSyntaxError(err) {
@@ -584,19 +851,49 @@ func (dir *Dir) TargetTemplate(instance *tengo.Instance) Target {
 // OptionFile returns a pointer to a mycli.File for this directory, representing
 // the dir's .skeema file, if one exists. The file will be read and parsed; any
 // errors in either process will be returned. The section specified by
-// dir.section will automatically be selected for use in the file if it exists.
+// dir.section, along with any sections it inherits from, will automatically be
+// selected for use in the file if they exist. Any section inheritance declared
+// by the file is recorded in the dir, affecting the dir's subdirs as well.
 func (dir *Dir) OptionFile() (*mycli.File, error) {
 	f := mycli.NewFile(dir.Path, ".skeema")
+	f.ExpandEnvVars = true
 	if err := f.Read(); err != nil {
 		return nil, err
 	}
 	if err := f.Parse(dir.Config); err != nil {
 		return nil, err
 	}
-	_ = f.UseSection(dir.section) // we don't care if the section doesn't exist
+	if err := checkSectionParents(f, dir.hasAncestorOptionFile); err != nil {
+		return nil, err
+	}
+	if parents := f.SectionParents(); len(parents) > 0 {
+		dir.sectionParents = dir.copySectionParents()
+		mergeSectionParents(dir.sectionParents, parents)
+	}
+	_ = f.UseSection(dir.Sections()...) // we don't care if the sections don't exist
 	return f, nil
 }
 
+// checkSectionParents returns an error if the parsed .skeema file f declares
+// section inheritance, but is not the top-level .skeema file, as indicated by
+// hasAncestor. Inheritance declared lower in the hierarchy is not permitted,
+// since it could not be applied consistently to option files in parent dirs,
+// which have already been added to the config.
+func checkSectionParents(f *mycli.File, hasAncestor bool) error {
+	if hasAncestor && len(f.SectionParents()) > 0 {
+		return fmt.Errorf("%s: section inheritance may only be declared in global option files or the top-level .skeema file", f.Path())
+	}
+	return nil
+}
+
+// copySectionParents returns a copy of the dir's section inheritance map, so
+// that declarations in a subdir's option file do not affect its siblings.
+func (dir *Dir) copySectionParents() map[string]string {
+	result := make(map[string]string, len(dir.sectionParents))
+	mergeSectionParents(result, dir.sectionParents)
+	return result
+}
+
 // cascadingOptionFiles returns a slice of *mycli.File, corresponding to the
 // option file in this dir as well as its parent dir hierarchy. Evaluation
 // of parent dirs stops once we hit either a directory containing .git, the
@@ -630,6 +927,7 @@ func (dir *Dir) cascadingOptionFiles() (files []*mycli.File, errReturn error) {
 				n = -1 // stop outer loop early, after done with this dir
 			} else if fi.Name() == ".skeema" {
 				f := mycli.NewFile(curPath, ".skeema")
+				f.ExpandEnvVars = true
 				if readErr := f.Read(); readErr != nil {
 					errReturn = readErr
 				} else {
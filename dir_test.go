@@ -1,8 +1,12 @@
 package main
 
 import (
+	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/skeema/mycli"
@@ -93,6 +97,48 @@ func TestInstances(t *testing.T) {
 	assertInstances(map[string]string{"host-wrapper": "/bin/echo -n", "host": "ignored"}, false)
 }
 
+func TestPasswordWrapper(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	countFile := filepath.Join(tmpdir, "count")
+
+	cmd := mycli.NewCommand("test", "1.0", "this is for testing", nil)
+	AddGlobalOptions(cmd)
+	cli := &mycli.CommandLine{
+		Command: cmd,
+	}
+	optionValues := map[string]string{
+		"host":             "pwwrapper1.db.host,pwwrapper2.db.host:3307",
+		"user":             "someone",
+		"password":         "ignored",
+		"password-wrapper": "echo {HOST} >> " + countFile + "; /usr/bin/printf '{USER}-{HOST}-{PORT}\\n'",
+	}
+	dir := &Dir{
+		Path:    "/tmp/dummydir",
+		Config:  mycli.NewConfig(cli, dummySource(optionValues)),
+		section: "production",
+	}
+	for n := 0; n < 2; n++ {
+		instances, err := dir.Instances()
+		if err != nil {
+			t.Fatalf("Unexpected error from Instances: %s", err)
+		} else if len(instances) != 2 {
+			t.Fatalf("Expected 2 instances, instead found %d", len(instances))
+		}
+		if instances[0].Password != "someone-pwwrapper1.db.host-3306" || instances[1].Password != "someone-pwwrapper2.db.host-3307" {
+			t.Errorf("Unexpected passwords from password-wrapper: %q, %q", instances[0].Password, instances[1].Password)
+		}
+	}
+	if contents, err := ioutil.ReadFile(countFile); err != nil {
+		t.Errorf("Unable to read %s: %s", countFile, err)
+	} else if string(contents) != "pwwrapper1.db.host\npwwrapper2.db.host\n" {
+		t.Errorf("Expected password-wrapper to be run once per host, instead found output %q", contents)
+	}
+}
+
 func TestInstanceDefaultParams(t *testing.T) {
 	getDir := func(connectOptions string) *Dir {
 		return &Dir{
@@ -141,3 +187,184 @@ func TestInstanceDefaultParams(t *testing.T) {
 		}
 	}
 }
+
+func TestSectionInheritance(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	if err := os.MkdirAll(filepath.Join(tmpdir, "app"), 0755); err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, ".skeema"), []byte("user=root\n[staging]\nhost=staging.example.com\nuser=stager\n[staging-eu : staging]\nhost=eu.example.com\n"), 0644); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, "app", ".skeema"), []byte("[staging]\nschema=app\nport=3307\n[staging-eu]\nport=3308\n"), 0644); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+
+	cfg := getConfig(map[string]string{"environment": "staging-eu", "host": "", "user": "", "schema": "", "port": ""})
+	dir, err := NewDir(filepath.Join(tmpdir, "app"), cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDir: %s", err)
+	}
+	if sections := dir.Sections(); strings.Join(sections, ",") != "staging-eu,staging" {
+		t.Errorf("Unexpected result from Sections: %v", sections)
+	}
+	expected := map[string]string{
+		"host":   "eu.example.com",
+		"user":   "stager",
+		"schema": "app",
+		"port":   "3308",
+	}
+	for name, expectedValue := range expected {
+		if value := dir.Config.Get(name); value != expectedValue {
+			t.Errorf("Expected option %s to have value %q, instead found %q", name, expectedValue, value)
+		}
+	}
+
+	// Obtaining the same dir via Subdirs of its parent should yield the same
+	// configuration
+	parentDir, err := NewDir(tmpdir, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDir: %s", err)
+	}
+	subdirs, err := parentDir.Subdirs()
+	if err != nil || len(subdirs) != 1 {
+		t.Fatalf("Unexpected result from Subdirs: %v, %v", subdirs, err)
+	}
+	for name, expectedValue := range expected {
+		if value := subdirs[0].Config.Get(name); value != expectedValue {
+			t.Errorf("Expected option %s to have value %q in subdir, instead found %q", name, expectedValue, value)
+		}
+	}
+
+	// Declaring inheritance below the top-level .skeema file is an error, with
+	// either method of obtaining the dir
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, "app", ".skeema"), []byte("[staging]\nschema=app\n[staging-us : staging]\nport=3309\n"), 0644); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if _, err := NewDir(filepath.Join(tmpdir, "app"), cfg); err == nil {
+		t.Error("Expected error from NewDir with inheritance declared in subdir, but no error returned")
+	}
+	if _, err := parentDir.Subdirs(); err == nil {
+		t.Error("Expected error from Subdirs with inheritance declared in subdir, but no error returned")
+	}
+
+	// Inheritance loops should be truncated rather than looping forever
+	parents := map[string]string{"a": "b", "b": "c", "c": "a"}
+	if chain := sectionChain("a", parents); strings.Join(chain, ",") != "a,b,c" {
+		t.Errorf("Unexpected result from sectionChain: %v", chain)
+	}
+	if chain := sectionChain("production", parents); strings.Join(chain, ",") != "production" {
+		t.Errorf("Unexpected result from sectionChain: %v", chain)
+	}
+}
+
+func TestOptionFileInclude(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	writeFile := func(relPath, contents string) {
+		t.Helper()
+		fullPath := filepath.Join(tmpdir, relPath)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Unable to create dir: %s", err)
+		}
+		if err := ioutil.WriteFile(fullPath, []byte(contents), 0644); err != nil {
+			t.Fatalf("Unable to write file: %s", err)
+		}
+	}
+	writeFile("shared/common.cnf", "port=3307\nuser=shared\n[production]\nhost=db.example.com\n")
+	writeFile("shared/conf.d/a.cnf", "connect-options=\"wait_timeout=10\"\n")
+	writeFile("shared/conf.d/b.cnf", "connect-options=\"wait_timeout=20\"\n")
+	writeFile("shared/conf.d/c.txt", "connect-options=\"wait_timeout=30\"\n")
+	writeFile("hostdir/.skeema", "!include ../shared/common.cnf\nuser=override\n!includedir ../shared/conf.d\n[production]\nschema=foo\n")
+
+	cfg := getConfig(map[string]string{"port": "", "user": "", "host": "", "schema": "", "connect-options": ""})
+	f := mycli.NewFile(tmpdir, "hostdir/.skeema")
+	if err := f.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error parsing file: %s", err)
+	}
+	if err := f.UseSection("production"); err != nil {
+		t.Fatalf("Unexpected error from UseSection: %s", err)
+	}
+	expected := map[string]string{
+		"port":            "3307",
+		"user":            "override",
+		"host":            "db.example.com",
+		"schema":          "foo",
+		"connect-options": "\"wait_timeout=20\"",
+	}
+	for name, expectedValue := range expected {
+		if value, ok := f.OptionValue(name); !ok || value != expectedValue {
+			t.Errorf("Expected option %s to have value %q, instead found %q, %t", name, expectedValue, value, ok)
+		}
+	}
+	expectedPaths := map[string]string{
+		"port":            filepath.Join(tmpdir, "shared/common.cnf"),
+		"user":            f.Path(),
+		"host":            filepath.Join(tmpdir, "shared/common.cnf"),
+		"connect-options": filepath.Join(tmpdir, "shared/conf.d/b.cnf"),
+	}
+	for name, expectedPath := range expectedPaths {
+		if actual := f.OptionPath(name); actual != expectedPath {
+			t.Errorf("Expected option %s to come from %s, instead found %s", name, expectedPath, actual)
+		}
+	}
+
+	// Rewriting the file should preserve include directives, and not copy
+	// included values into the file
+	f.SetOptionValue("production", "schema", "bar")
+	if err := f.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %s", err)
+	}
+	rewritten := mycli.NewFile(f.Path())
+	if err := rewritten.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error re-parsing rewritten file: %s", err)
+	}
+	rewritten.UseSection("production")
+	if value, _ := rewritten.OptionValue("schema"); value != "bar" {
+		t.Errorf("Expected rewritten file to have schema=bar, instead found %q", value)
+	}
+	if value, _ := rewritten.OptionValue("host"); value != "db.example.com" {
+		t.Errorf("Expected rewritten file to still include host, instead found %q", value)
+	}
+	if contents, _ := ioutil.ReadFile(f.Path()); strings.Contains(string(contents), "port") {
+		t.Errorf("Expected rewritten file to not contain included values, instead found contents:\n%s", contents)
+	}
+
+	// Include cycles and missing files should be errors
+	writeFile("cycle/.skeema", "!include other.cnf\n")
+	writeFile("cycle/other.cnf", "port=3308\n!include .skeema\n")
+	if err := mycli.NewFile(tmpdir, "cycle/.skeema").Parse(cfg); err == nil {
+		t.Error("Expected include cycle to return an error, but it did not")
+	}
+	writeFile("missing/.skeema", "!include doesnt-exist.cnf\n")
+	if err := mycli.NewFile(tmpdir, "missing/.skeema").Parse(cfg); err == nil {
+		t.Error("Expected include of nonexistent file to return an error, but it did not")
+	}
+}
+
+func TestSQLFileForTable(t *testing.T) {
+	dir := &Dir{Path: "/tmp/dummydir"}
+	target := &Target{
+		Dir:        dir,
+		TableFiles: map[string]string{"users": "001_users.sql"},
+	}
+	if sf := target.SQLFileForTable("users"); sf.Path() != "/tmp/dummydir/001_users.sql" {
+		t.Errorf("Unexpected path for existing table: %s", sf.Path())
+	}
+	if sf := target.SQLFileForTable("posts"); sf.Path() != "/tmp/dummydir/posts.sql" {
+		t.Errorf("Unexpected path for new table: %s", sf.Path())
+	}
+	// New tables must not clobber files containing other tables
+	target.TableFiles["accounts"] = "posts.sql"
+	target.TableFiles["comments"] = "posts_2.sql"
+	if sf := target.SQLFileForTable("posts"); sf.Path() != "/tmp/dummydir/posts_3.sql" {
+		t.Errorf("Unexpected path for new table with colliding file name: %s", sf.Path())
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/skeema/mycli"
+)
+
+// This file contains logic for the dry-run option of commands that modify the
+// filesystem, such as init and pull. In dry-run mode, file and directory
+// changes are described instead of being made: changes to file contents are
+// displayed on STDOUT as unified diffs, and the usual log messages describe
+// what would have been done.
+
+// dryRunChanges tracks how many filesystem changes have been skipped due to
+// dry-run mode. Commands that support dry-run examine this upon completion, to
+// determine their exit code.
+var dryRunChanges int
+
+// DryRun returns true if the current command supports the dry-run option, and
+// it is enabled.
+func (dir *Dir) DryRun() bool {
+	_, ok := dir.Config.CLI.Command.Options()["dry-run"]
+	return ok && dir.Config.GetBool("dry-run")
+}
+
+// fsVerb returns past, a past-tense verb for use in a log message describing a
+// filesystem change, or conditional if the change was skipped due to dry-run
+// mode.
+func (dir *Dir) fsVerb(past, conditional string) string {
+	if dir.DryRun() {
+		return conditional
+	}
+	return past
+}
+
+// rewriteOptionFile overwrites an existing option file with its current
+// in-memory contents. In dry-run mode, a diff of the changes is displayed
+// instead.
+func (dir *Dir) rewriteOptionFile(f *mycli.File) error {
+	if !dir.DryRun() {
+		return f.Write(true)
+	}
+	oldContents, _ := ioutil.ReadFile(f.Path()) // if unreadable, display entire new contents
+	showFileChange(f.Path(), string(oldContents), f.Render())
+	return nil
+}
+
+// showFileChange displays a unified diff on STDOUT, describing the change to
+// the file at path from oldContents to newContents, and records the change as
+// having been skipped due to dry-run mode. oldContents should be blank for new
+// files.
+func showFileChange(path, oldContents, newContents string) {
+	fromFile := path
+	if oldContents == "" {
+		fromFile = "/dev/null"
+	}
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(oldContents),
+		B:        difflib.SplitLines(newContents),
+		FromFile: fromFile,
+		ToFile:   path,
+		Context:  3,
+	}
+	if diffText, err := difflib.GetUnifiedDiffString(diff); err == nil && diffText != "" {
+		fmt.Println(diffText)
+	}
+	dryRunChanges++
+}
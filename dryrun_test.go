@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skeema/mycli"
+)
+
+func TestDryRun(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	cmd := mycli.NewCommand("test", "1.0", "this is for testing", nil)
+	cmd.AddOption(mycli.BoolOption("dry-run", 0, false, "dry run"))
+	cli := &mycli.CommandLine{
+		Command:      cmd,
+		OptionValues: map[string]string{"dry-run": "1"},
+	}
+	dir := &Dir{
+		Path:    tmpdir,
+		Config:  mycli.NewConfig(cli),
+		section: "production",
+	}
+	if !dir.DryRun() {
+		t.Fatal("Expected DryRun to return true, but it did not")
+	}
+	if nonDryDir := (&Dir{Config: getConfig(map[string]string{})}); nonDryDir.DryRun() {
+		t.Error("Expected DryRun to return false for command without dry-run option, but it did not")
+	}
+
+	origContents := "CREATE TABLE `foo` (id int);\n"
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, "foo.sql"), []byte(origContents), 0644); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	before := dryRunChanges
+
+	sf := SQLFile{Dir: dir, FileName: "foo.sql", Contents: "CREATE TABLE `foo` (\n  `id` int(11) DEFAULT NULL\n)"}
+	if _, err := sf.Write(); err != nil {
+		t.Errorf("Unexpected error from Write: %s", err)
+	}
+	if err := sf.Delete(); err != nil {
+		t.Errorf("Unexpected error from Delete: %s", err)
+	}
+	if contents, err := ioutil.ReadFile(sf.Path()); err != nil || string(contents) != origContents {
+		t.Errorf("Expected file to be unchanged in dry-run mode, instead found %q, %v", contents, err)
+	}
+
+	subdir := &Dir{Path: filepath.Join(tmpdir, "newdir"), Config: dir.Config}
+	if created, err := subdir.CreateIfMissing(); !created || err != nil {
+		t.Errorf("Unexpected result from CreateIfMissing: %t, %v", created, err)
+	} else if subdir.Exists() {
+		t.Error("Expected CreateIfMissing to not create dir in dry-run mode, but it did")
+	}
+	optionFile := mycli.NewFile(".skeema")
+	optionFile.SetOptionValue("", "schema", "foo")
+	if err := subdir.CreateOptionFile(optionFile); err != nil {
+		t.Errorf("Unexpected error from CreateOptionFile: %s", err)
+	} else if subdir.HasOptionFile() {
+		t.Error("Expected CreateOptionFile to not create file in dry-run mode, but it did")
+	}
+	if err := dir.Delete(); err != nil || !dir.Exists() {
+		t.Errorf("Expected Delete to not remove dir in dry-run mode; err=%v", err)
+	}
+
+	if changes := dryRunChanges - before; changes != 5 {
+		t.Errorf("Expected 5 changes to be recorded, instead found %d", changes)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvSource is an OptionValuer providing option values from environment
+// variables. The variable name for an option is formed by uppercasing the
+// option name, replacing dashes with underscores, and adding a SKEEMA_ prefix.
+// For example, the alter-wrapper option may be set via SKEEMA_ALTER_WRAPPER.
+type EnvSource struct{}
+
+// EnvVarName returns the name of the environment variable corresponding to
+// the supplied option name.
+func EnvVarName(optionName string) string {
+	return "SKEEMA_" + strings.ToUpper(strings.Replace(optionName, "-", "_", -1))
+}
+
+// OptionValue returns the value of the environment variable corresponding to
+// optionName, if the variable is set.
+func (es EnvSource) OptionValue(optionName string) (string, bool) {
+	return os.LookupEnv(EnvVarName(optionName))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skeema/mycli"
+)
+
+func TestEnvSource(t *testing.T) {
+	os.Setenv("SKEEMA_ALTER_WRAPPER", "/bin/echo {CLAUSES}")
+	os.Setenv("SKEEMA_USER", "")
+	defer os.Unsetenv("SKEEMA_ALTER_WRAPPER")
+	defer os.Unsetenv("SKEEMA_USER")
+
+	if name := EnvVarName("alter-wrapper"); name != "SKEEMA_ALTER_WRAPPER" {
+		t.Errorf("Unexpected result from EnvVarName: %s", name)
+	}
+	var es EnvSource
+	if value, ok := es.OptionValue("alter-wrapper"); !ok || value != "/bin/echo {CLAUSES}" {
+		t.Errorf("Unexpected result from OptionValue: %q, %t", value, ok)
+	}
+	if value, ok := es.OptionValue("user"); !ok || value != "" {
+		t.Errorf("Expected set-but-empty variable to be returned, instead found %q, %t", value, ok)
+	}
+	if value, ok := es.OptionValue("password"); ok {
+		t.Errorf("Expected unset variable to be absent, instead found %q", value)
+	}
+}
+
+func TestOptionFileEnvVars(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	os.Setenv("SKEEMA_TEST_HOST", "db.example.com")
+	defer os.Unsetenv("SKEEMA_TEST_HOST")
+	os.Unsetenv("SKEEMA_TEST_PORT")
+
+	parseFile := func(contents string) (*mycli.File, error) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(tmpdir, ".skeema"), []byte(contents), 0644); err != nil {
+			t.Fatalf("Unable to write file: %s", err)
+		}
+		f := mycli.NewFile(tmpdir, ".skeema")
+		f.ExpandEnvVars = true
+		if err := f.Read(); err != nil {
+			t.Fatalf("Unable to read file: %s", err)
+		}
+		cfg := getConfig(map[string]string{"host": "", "port": "", "user": "", "password": ""})
+		return f, f.Parse(cfg)
+	}
+
+	f, err := parseFile("host=${SKEEMA_TEST_HOST}\nport=${SKEEMA_TEST_PORT:-3307}\nuser=x${SKEEMA_TEST_HOST}y\npassword=p$$a$${SKEEMA_TEST_HOST}\n")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing file: %s", err)
+	}
+	expected := map[string]string{
+		"host":     "db.example.com",
+		"port":     "3307",
+		"user":     "xdb.example.comy",
+		"password": "p$$a${SKEEMA_TEST_HOST}",
+	}
+	for name, expectedValue := range expected {
+		if value, ok := f.OptionValue(name); !ok || value != expectedValue {
+			t.Errorf("Expected option %s to have value %q, instead found %q, %t", name, expectedValue, value, ok)
+		}
+	}
+
+	for _, contents := range []string{"port=${SKEEMA_TEST_PORT}\n", "host=${SKEEMA_TEST_HOST\n", "host=${}\n"} {
+		if _, err := parseFile(contents); err == nil {
+			t.Errorf("Expected parse error for contents %q, but no error returned", contents)
+		}
+	}
+}
@@ -53,6 +53,8 @@ func (ev *ExitValue) Error() string {
 // an ExitValue, its Code will be used for the program's exit code. Otherwise,
 // if err is nil, exit code 0 will be used; if non-nil then exit code 2.
 func Exit(err error) {
+	shutdownLocalWorkspaces()
+	closeSSHTunnels()
 	if err == nil {
 		log.Debug("Exit code 0 (SUCCESS)")
 		os.Exit(0)
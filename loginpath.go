@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// This file contains logic for reading credentials from the obfuscated login
+// path file created by mysql_config_editor, typically ~/.mylogin.cnf.
+
+// loginPathOptions lists the options that may be obtained from a login path.
+var loginPathOptions = map[string]bool{
+	"user":     true,
+	"password": true,
+	"host":     true,
+	"port":     true,
+	"socket":   true,
+}
+
+// LoginPathSource is an OptionValuer providing the options stored in a login
+// path. Values in the [client] section of the login path file are overridden
+// by values in the named login path's section.
+type LoginPathSource struct {
+	Path   string
+	Name   string
+	values map[string]string
+}
+
+// LoginPathFile returns the path to the login path file: the value of the
+// MYSQL_TEST_LOGIN_FILE environment variable if set, or else .mylogin.cnf in
+// the user's home directory.
+func LoginPathFile() string {
+	if path := os.Getenv("MYSQL_TEST_LOGIN_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(filepath.Clean(os.Getenv("HOME")), ".mylogin.cnf")
+}
+
+// NewLoginPathSource reads and decrypts the login path file at path, and
+// returns a LoginPathSource for the login path called name. An error is
+// returned if the file cannot be read or decrypted, or does not contain the
+// login path.
+func NewLoginPathSource(path, name string) (*LoginPathSource, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := decryptLoginPathFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt %s: %s", path, err)
+	}
+	sections := parseLoginPathContents(contents)
+	if _, ok := sections[name]; !ok {
+		return nil, fmt.Errorf("Login path %s not found in %s", name, path)
+	}
+	lps := &LoginPathSource{
+		Path:   path,
+		Name:   name,
+		values: make(map[string]string),
+	}
+	for _, section := range []string{"client", name} {
+		for key, value := range sections[section] {
+			if loginPathOptions[key] {
+				lps.values[key] = value
+			}
+		}
+	}
+	return lps, nil
+}
+
+// OptionValue returns the value for the requested option from the login path,
+// if present.
+func (lps *LoginPathSource) OptionValue(optionName string) (string, bool) {
+	value, ok := lps.values[optionName]
+	return value, ok
+}
+
+// decryptLoginPathFile decrypts the contents of a login path file. The file
+// begins with 4 unused bytes, followed by a 20-byte key which is folded into a
+// 16-byte AES-128 key. The remainder of the file consists of chunks, each
+// containing a 4-byte little-endian length followed by that many bytes of
+// AES-128-ECB ciphertext, which decrypts to a line of an option file.
+func decryptLoginPathFile(data []byte) (string, error) {
+	const keyOffset, keyLen = 4, 20
+	if len(data) < keyOffset+keyLen {
+		return "", errors.New("file is too short")
+	}
+	key := make([]byte, aes.BlockSize)
+	for n, b := range data[keyOffset : keyOffset+keyLen] {
+		key[n%aes.BlockSize] ^= b
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	var result bytes.Buffer
+	pos := keyOffset + keyLen
+	for pos+4 <= len(data) {
+		chunkLen := int(binary.LittleEndian.Uint32(data[pos : pos+4]))
+		pos += 4
+		if chunkLen == 0 || chunkLen%aes.BlockSize != 0 || pos+chunkLen > len(data) {
+			return "", errors.New("invalid chunk length")
+		}
+		chunk := make([]byte, chunkLen)
+		for n := 0; n < chunkLen; n += aes.BlockSize {
+			block.Decrypt(chunk[n:n+aes.BlockSize], data[pos+n:pos+n+aes.BlockSize])
+		}
+		pos += chunkLen
+		padLen := int(chunk[chunkLen-1])
+		if padLen < 1 || padLen > aes.BlockSize {
+			return "", errors.New("invalid padding")
+		}
+		result.Write(chunk[:chunkLen-padLen])
+	}
+	return result.String(), nil
+}
+
+// parseLoginPathContents parses the decrypted contents of a login path file,
+// returning a map of section name to option name to value. Quotes around
+// values are removed.
+func parseLoginPathContents(contents string) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+	var current map[string]string
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			current = sections[name]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		tokens := strings.SplitN(line, "=", 2)
+		key := strings.Replace(strings.ToLower(strings.TrimSpace(tokens[0])), "_", "-", -1)
+		var value string
+		if len(tokens) > 1 {
+			value = strings.TrimSpace(tokens[1])
+			if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+				value = value[1 : len(value)-1]
+			}
+		}
+		current[key] = value
+	}
+	return sections
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// encryptLoginPathFile is the inverse of decryptLoginPathFile, producing data
+// in the same format as mysql_config_editor.
+func encryptLoginPathFile(t *testing.T, contents string) []byte {
+	t.Helper()
+	rawKey := []byte("0123456789abcdefghij")
+	key := make([]byte, aes.BlockSize)
+	for n, b := range rawKey {
+		key[n%aes.BlockSize] ^= b
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Unable to create cipher: %s", err)
+	}
+	var result bytes.Buffer
+	result.Write([]byte{0, 0, 0, 0})
+	result.Write(rawKey)
+	for _, line := range strings.SplitAfter(contents, "\n") {
+		if line == "" {
+			continue
+		}
+		padLen := aes.BlockSize - len(line)%aes.BlockSize
+		plain := append([]byte(line), bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+		cipher := make([]byte, len(plain))
+		for n := 0; n < len(plain); n += aes.BlockSize {
+			block.Encrypt(cipher[n:n+aes.BlockSize], plain[n:n+aes.BlockSize])
+		}
+		binary.Write(&result, binary.LittleEndian, uint32(len(cipher)))
+		result.Write(cipher)
+	}
+	return result.Bytes()
+}
+
+func TestNewLoginPathSource(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	loginFile := filepath.Join(tmpdir, ".mylogin.cnf")
+	contents := "[client]\nuser = \"someone\"\npassword = \"c0mplex=pass\"\n[prod]\nuser = \"deployer\"\nhost = \"db.example.com\"\nport = 3307\nssl_ca = \"/etc/ca.pem\"\n"
+	if err := ioutil.WriteFile(loginFile, encryptLoginPathFile(t, contents), 0600); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+
+	lps, err := NewLoginPathSource(loginFile, "prod")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewLoginPathSource: %s", err)
+	}
+	expected := map[string]string{
+		"user":     "deployer",
+		"password": "c0mplex=pass",
+		"host":     "db.example.com",
+		"port":     "3307",
+	}
+	for name, expectedValue := range expected {
+		if value, ok := lps.OptionValue(name); !ok || value != expectedValue {
+			t.Errorf("Expected option %s to have value %q, instead found %q, %t", name, expectedValue, value, ok)
+		}
+	}
+	for _, name := range []string{"socket", "ssl-ca", "schema"} {
+		if value, ok := lps.OptionValue(name); ok {
+			t.Errorf("Expected option %s to be absent, instead found %q", name, value)
+		}
+	}
+
+	if _, err := NewLoginPathSource(loginFile, "staging"); err == nil {
+		t.Error("Expected NewLoginPathSource to return error for nonexistent login path, but it did not")
+	}
+	if _, err := NewLoginPathSource(filepath.Join(tmpdir, "doesnt-exist"), "prod"); err == nil {
+		t.Error("Expected NewLoginPathSource to return error for nonexistent file, but it did not")
+	}
+	if err := ioutil.WriteFile(loginFile, []byte("[client]\nuser=someone\n"), 0600); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if _, err := NewLoginPathSource(loginFile, "client"); err == nil {
+		t.Error("Expected NewLoginPathSource to return error for unencrypted file, but it did not")
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+	"github.com/skeema/tengo"
+)
+
+// This file contains logic for discovering replicas of a database instance,
+// confirming that they have converged on the expected schema after a push, and
+// preventing pushes directly to read-only replicas.
+
+// ReplicaAddresses returns the addresses of replicas of instance, in
+// host:port format. If the replica-hosts option is set, its value is used
+// as-is. Otherwise, replicas are discovered via SHOW SLAVE HOSTS, which
+// requires replicas to set report_host; if that returns nothing, the
+// processlist of replication dump threads is used instead, assuming replicas
+// listen on the same port as instance.
+func (dir *Dir) ReplicaAddresses(instance *tengo.Instance) ([]string, error) {
+	if dir.Config.Changed("replica-hosts") {
+		port := dir.Config.GetIntOrDefault("port")
+		var addresses []string
+		for _, host := range dir.Config.GetSlice("replica-hosts", ',', true) {
+			splitHost, splitPort, err := tengo.SplitHostOptionalPort(host)
+			if err != nil {
+				return nil, err
+			}
+			if splitPort == 0 {
+				splitPort = port
+			}
+			splitHost = strings.TrimSuffix(strings.TrimPrefix(splitHost, "["), "]")
+			addresses = append(addresses, net.JoinHostPort(splitHost, strconv.Itoa(splitPort)))
+		}
+		return addresses, nil
+	}
+
+	db, err := instance.Connect("", "")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := queryRowMaps(db, "SHOW SLAVE HOSTS")
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, row := range rows {
+		if row["host"] != "" && row["port"] != "" && row["port"] != "0" {
+			addresses = append(addresses, net.JoinHostPort(row["host"], row["port"]))
+		}
+	}
+	if len(addresses) > 0 {
+		return addresses, nil
+	}
+
+	var hosts []string
+	query := "SELECT host FROM information_schema.processlist WHERE command LIKE 'Binlog Dump%'"
+	if err := db.Select(&hosts, query); err != nil {
+		return nil, err
+	}
+	_, port := InstanceRemoteHostPort(instance)
+	for _, host := range hosts {
+		if splitHost, _, err := net.SplitHostPort(host); err == nil {
+			host = splitHost
+		}
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+	return addresses, nil
+}
+
+// waitForReplica blocks until replica has applied all events that were in
+// primary's binary log at the time of the call, or until timeout elapses.
+// Only direct replicas of primary are supported.
+func waitForReplica(primary, replica *tengo.Instance, timeout time.Duration) error {
+	primaryDB, err := primary.Connect("", "")
+	if err != nil {
+		return err
+	}
+	rows, err := queryRowMaps(primaryDB, "SHOW MASTER STATUS")
+	if err != nil {
+		return err
+	} else if len(rows) == 0 || rows[0]["file"] == "" {
+		return fmt.Errorf("Binary logging is not enabled on %s", primary)
+	}
+	file := rows[0]["file"]
+	pos, err := strconv.ParseUint(rows[0]["position"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Unable to parse binary log position from %s: %s", primary, err)
+	}
+
+	replicaDB, err := replica.Connect("", "")
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for time.Since(start) < timeout {
+		// Only using a timeout of 1 sec on each query to avoid exceeding the
+		// connection's read timeout, and to avoid issues with query killers
+		var result sql.NullInt64
+		if err := replicaDB.QueryRow("SELECT MASTER_POS_WAIT(?, ?, 1)", file, pos).Scan(&result); err != nil {
+			return err
+		} else if !result.Valid {
+			return errors.New("Replication SQL thread is not running, or server is not a replica")
+		} else if result.Int64 >= 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("Replication did not catch up to %s:%d within %s", file, pos, timeout)
+}
+
+// checkReplicas discovers the replicas of t.Instance, waits for each to catch
+// up on replication, and then confirms that each replica's copy of the schema
+// matches t.SchemaFromDir. Any problems are logged. The return value is the
+// number of replicas that diverged or could not be checked.
+func (t *Target) checkReplicas() (errCount int) {
+	schemaName := t.SchemaFromDir.Name
+	addresses, err := t.Dir.ReplicaAddresses(t.Instance)
+	if err != nil {
+		log.Errorf("Unable to discover replicas of %s: %s", t.Instance, err)
+		return 1
+	} else if len(addresses) == 0 {
+		log.Warnf("%s %s: no replicas found to check", t.Instance, schemaName)
+		return 0
+	}
+	timeout := time.Duration(t.Dir.Config.GetIntOrDefault("replica-wait-timeout")) * time.Second
+
+	for _, address := range addresses {
+		host, portString, _ := net.SplitHostPort(address) // no error possible, since address built by ReplicaAddresses
+		port, _ := strconv.Atoi(portString)
+		if strings.Contains(host, ":") {
+			host = fmt.Sprintf("[%s]", host) // ipv6 addresses must be bracketed in DSN
+		}
+		replica, err := t.Dir.RelatedInstance(host, port)
+		if err != nil {
+			log.Errorf("Unable to check replica %s of %s: %s", address, t.Instance, err)
+			errCount++
+			continue
+		}
+		if err := waitForReplica(t.Instance, replica, timeout); err != nil {
+			log.Errorf("Unable to check replica %s of %s: %s", replica, t.Instance, err)
+			errCount++
+			continue
+		}
+		if err := t.confirmSchema(replica); err != nil {
+			log.Errorf("Replica %s %s has diverged from %s/*.sql: %s", replica, schemaName, t.Dir, err)
+			errCount++
+			continue
+		}
+		log.Infof("Replica %s %s: verified, schema matches %s/*.sql", replica, schemaName, t.Dir)
+	}
+	return errCount
+}
+
+// ReplicaPushMode returns the dir's replica-push option value, indicating how
+// target instances that are read-only replicas should be handled: "REFUSE",
+// "REDIRECT", or "ALLOW". If the current command does not execute DDL (for
+// example, `skeema diff` or `skeema push --dry-run`), "ALLOW" is returned,
+// since there is no risk in examining a replica.
+func (dir *Dir) ReplicaPushMode() (string, error) {
+	if _, ok := dir.Config.CLI.Command.Options()["replica-push"]; !ok || dir.Config.GetBool("dry-run") {
+		return "ALLOW", nil
+	}
+	return dir.Config.GetEnum("replica-push", "REFUSE", "REDIRECT", "ALLOW")
+}
+
+// resolveReplicaInstances examines each of instances to determine whether it
+// is a read-only replica, based on the dir's replica-push option. Depending on
+// the mode, read-only instances are either refused (recording an error in
+// targetsByInstance) or replaced by their primary. Other instances are
+// returned as-is.
+func resolveReplicaInstances(dir *Dir, instances []*tengo.Instance, targetsByInstance TargetGroupMap) []*tengo.Instance {
+	mode, err := dir.ReplicaPushMode()
+	if err != nil {
+		targetsByInstance.AddDirError(dir, err)
+		return nil
+	} else if mode == "ALLOW" {
+		return instances
+	}
+
+	result := make([]*tengo.Instance, 0, len(instances))
+	seen := make(map[*tengo.Instance]bool, len(instances))
+	for _, inst := range instances {
+		readOnly, err := instanceReadOnly(inst)
+		if err != nil {
+			targetsByInstance.AddInstanceError(inst, dir, fmt.Errorf("Unable to determine if instance is read-only: %s", err))
+			continue
+		}
+		if !readOnly {
+			if sourceHost, sourcePort, err := replicationSource(inst); err == nil && sourceHost != "" {
+				log.Warnf("%s is replicating from %s:%d, but is not read-only; proceeding with push", inst, sourceHost, sourcePort)
+			}
+		} else if mode == "REFUSE" {
+			err := fmt.Errorf("Instance has read_only enabled, and is likely a replica. Refusing to push, since running DDL on a replica may break replication. Use --replica-push=redirect to push to its primary instead")
+			targetsByInstance.AddInstanceError(inst, dir, err)
+			continue
+		} else {
+			primary, err := dir.primaryInstance(inst)
+			if err != nil {
+				targetsByInstance.AddInstanceError(inst, dir, fmt.Errorf("Instance has read_only enabled, but unable to redirect push to its primary: %s", err))
+				continue
+			}
+			log.Warnf("%s is a read-only replica; redirecting push for %s to its primary %s", inst, dir, primary)
+			inst = primary
+		}
+		if !seen[inst] {
+			seen[inst] = true
+			result = append(result, inst)
+		}
+	}
+	return result
+}
+
+// primaryInstance follows the replication hierarchy upwards from instance, and
+// returns the first writable instance found.
+func (dir *Dir) primaryInstance(instance *tengo.Instance) (*tengo.Instance, error) {
+	const maxHops = 5
+	current := instance
+	for hops := 0; hops < maxHops; hops++ {
+		sourceHost, sourcePort, err := replicationSource(current)
+		if err != nil {
+			return nil, err
+		} else if sourceHost == "" {
+			return nil, fmt.Errorf("%s is not replicating from any source", current)
+		}
+		if strings.Contains(sourceHost, ":") {
+			sourceHost = fmt.Sprintf("[%s]", sourceHost) // ipv6 addresses must be bracketed in DSN
+		}
+		source, err := dir.RelatedInstance(sourceHost, sourcePort)
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := source.CanConnect(); !ok {
+			return nil, fmt.Errorf("Unable to connect to %s: %s", source, err)
+		}
+		readOnly, err := instanceReadOnly(source)
+		if err != nil {
+			return nil, err
+		} else if !readOnly {
+			return source, nil
+		}
+		current = source
+	}
+	return nil, fmt.Errorf("No writable instance found within %d levels of replication above %s", maxHops, instance)
+}
+
+// instanceReadOnly returns true if instance has read_only or super_read_only
+// enabled.
+func instanceReadOnly(instance *tengo.Instance) (bool, error) {
+	db, err := instance.Connect("", "")
+	if err != nil {
+		return false, err
+	}
+	rows, err := queryRowMaps(db, "SHOW GLOBAL VARIABLES WHERE Variable_name IN ('read_only', 'super_read_only')")
+	if err != nil {
+		return false, err
+	}
+	for _, row := range rows {
+		if value := strings.ToUpper(row["value"]); value == "ON" || value == "1" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// replicationSource returns the host and port that instance replicates from,
+// according to SHOW SLAVE STATUS. If instance is not configured as a replica,
+// a blank host is returned. An error is returned if the instance uses
+// multi-source replication, since its primary is ambiguous in that case.
+func replicationSource(instance *tengo.Instance) (string, int, error) {
+	db, err := instance.Connect("", "")
+	if err != nil {
+		return "", 0, err
+	}
+	rows, err := queryRowMaps(db, "SHOW SLAVE STATUS")
+	if err != nil {
+		return "", 0, err
+	} else if len(rows) == 0 || rows[0]["master_host"] == "" {
+		return "", 0, nil
+	} else if len(rows) > 1 {
+		return "", 0, fmt.Errorf("%s uses multi-source replication", instance)
+	}
+	port, err := strconv.Atoi(rows[0]["master_port"])
+	if err != nil {
+		return "", 0, fmt.Errorf("Unable to parse replication source port from %s: %s", instance, err)
+	}
+	return rows[0]["master_host"], port, nil
+}
+
+// queryRowMaps runs query on db, and returns each row as a map of lowercased
+// column name to string value. This is useful for SHOW commands, which may
+// return differing sets of columns depending on server version and flavor.
+func queryRowMaps(db *sqlx.DB, query string) ([]map[string]string, error) {
+	rows, err := db.Queryx(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []map[string]string
+	for rows.Next() {
+		raw := make(map[string]interface{})
+		if err := rows.MapScan(raw); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(raw))
+		for name, value := range raw {
+			if b, ok := value.([]byte); ok {
+				row[strings.ToLower(name)] = string(b)
+			} else if value != nil {
+				row[strings.ToLower(name)] = fmt.Sprint(value)
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
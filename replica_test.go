@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplicaAddressesFromConfig(t *testing.T) {
+	dir := &Dir{
+		Path: "/tmp/dummydir",
+		Config: getConfig(map[string]string{ // see dir_test.go
+			"replica-hosts": "replica1.example.com, 10.0.0.5:3307,[2001:db8::5]",
+			"port":          "3306",
+		}),
+		section: "production",
+	}
+	addresses, err := dir.ReplicaAddresses(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error from ReplicaAddresses: %s", err)
+	}
+	expected := []string{"replica1.example.com:3306", "10.0.0.5:3307", "[2001:db8::5]:3306"}
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("Expected ReplicaAddresses to return %v, instead found %v", expected, addresses)
+	}
+
+	dir.Config = getConfig(map[string]string{
+		"replica-hosts": "replica1.example.com:abc",
+		"port":          "3306",
+	})
+	if _, err := dir.ReplicaAddresses(nil); err == nil {
+		t.Error("Expected ReplicaAddresses to return error for invalid port, but it did not")
+	}
+}
+
+func TestReplicaPushMode(t *testing.T) {
+	assertMode := func(expected string, expectError bool, optValues map[string]string) {
+		t.Helper()
+		dir := &Dir{
+			Path:    "/tmp/dummydir",
+			Config:  getConfig(optValues), // see dir_test.go
+			section: "production",
+		}
+		mode, err := dir.ReplicaPushMode()
+		if expectError && err == nil {
+			t.Errorf("Expected ReplicaPushMode to return error for %v, but it did not", optValues)
+		} else if !expectError && err != nil {
+			t.Errorf("Unexpected error from ReplicaPushMode for %v: %s", optValues, err)
+		} else if mode != expected {
+			t.Errorf("Expected ReplicaPushMode to return %q for %v, instead found %q", expected, optValues, mode)
+		}
+	}
+	assertMode("ALLOW", false, map[string]string{"dry-run": ""})
+	assertMode("REFUSE", false, map[string]string{"replica-push": "refuse", "dry-run": ""})
+	assertMode("REDIRECT", false, map[string]string{"replica-push": "Redirect", "dry-run": ""})
+	assertMode("ALLOW", false, map[string]string{"replica-push": "redirect", "dry-run": "1"})
+	assertMode("", true, map[string]string{"replica-push": "ignore", "dry-run": ""})
+}
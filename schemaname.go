@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// This file contains logic for mapping between the schema names used in .skeema
+// files and directory names, and the actual schema names on database
+// instances, based on the schema-name-template option. It also handles
+// filtering which schemas on an instance are relevant, based on the
+// include-schemas and exclude-schemas options.
+
+var reSchemaNameVar = regexp.MustCompile(`{([A-Za-z_]+)}`)
+
+// schemaNameTemplate returns the dir's schema-name-template option value, or
+// an empty string if no template is in use. An error is returned if the
+// template is invalid.
+func (dir *Dir) schemaNameTemplate() (string, error) {
+	template := dir.Config.Get("schema-name-template")
+	if template == "" {
+		return "", nil
+	}
+	var schemaVarCount int
+	for _, match := range reSchemaNameVar.FindAllStringSubmatch(template, -1) {
+		switch strings.ToUpper(match[1]) {
+		case "SCHEMA":
+			schemaVarCount++
+		case "ENVIRONMENT":
+		default:
+			return "", fmt.Errorf("Option schema-name-template contains unknown variable %s", match[0])
+		}
+	}
+	if schemaVarCount != 1 {
+		return "", errors.New("Option schema-name-template must contain {SCHEMA} exactly once")
+	}
+	return template, nil
+}
+
+// ActualSchemaName applies the dir's schema-name-template to logicalName, a
+// schema name as used in .skeema files, returning the corresponding schema name
+// on database instances. If no template is in use, logicalName is returned
+// as-is.
+func (dir *Dir) ActualSchemaName(logicalName string) (string, error) {
+	template, err := dir.schemaNameTemplate()
+	if err != nil || template == "" {
+		return logicalName, err
+	}
+	return reSchemaNameVar.ReplaceAllStringFunc(template, func(v string) string {
+		if strings.EqualFold(v, "{SCHEMA}") {
+			return logicalName
+		}
+		return dir.section
+	}), nil
+}
+
+// LogicalSchemaName is the inverse of ActualSchemaName: it returns the schema
+// name, as used in .skeema files, that maps to actualName via the dir's
+// schema-name-template. The second return value is false if actualName does not
+// match the template, indicating that the schema does not belong to this dir's
+// environment. If no template is in use, actualName is returned as-is.
+func (dir *Dir) LogicalSchemaName(actualName string) (string, bool, error) {
+	template, err := dir.schemaNameTemplate()
+	if err != nil {
+		return "", false, err
+	} else if template == "" {
+		return actualName, true, nil
+	}
+	pattern := "^"
+	var pos int
+	for _, loc := range reSchemaNameVar.FindAllStringIndex(template, -1) {
+		pattern += regexp.QuoteMeta(template[pos:loc[0]])
+		if strings.EqualFold(template[loc[0]:loc[1]], "{SCHEMA}") {
+			pattern += "(.+)"
+		} else {
+			pattern += regexp.QuoteMeta(dir.section)
+		}
+		pos = loc[1]
+	}
+	pattern += regexp.QuoteMeta(template[pos:]) + "$"
+	matches := regexp.MustCompile(pattern).FindStringSubmatch(actualName)
+	if matches == nil {
+		return "", false, nil
+	}
+	return matches[1], true, nil
+}
+
+// SchemaIncluded returns true if the supplied schema name, as it appears on
+// database instances, passes the dir's include-schemas and exclude-schemas
+// filters. These filters apply whenever schema names are discovered from an
+// instance, rather than being listed explicitly.
+func (dir *Dir) SchemaIncluded(name string) (bool, error) {
+	if include, err := dir.schemaNameMatcher("include-schemas"); err != nil {
+		return false, err
+	} else if include != nil && !include(name) {
+		return false, nil
+	}
+	if exclude, err := dir.schemaNameMatcher("exclude-schemas"); err != nil {
+		return false, err
+	} else if exclude != nil && exclude(name) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// schemaNameMatcher returns a function for testing whether a schema name
+// matches the value of the supplied option. If the option value is wrapped in
+// forward slashes, it is treated as a regular expression. Otherwise, it is
+// treated as a comma-separated list of glob patterns. In either case, the
+// entire name must match. If the option is not set, a nil function is
+// returned.
+func (dir *Dir) schemaNameMatcher(optionName string) (func(string) bool, error) {
+	value := dir.Config.Get(optionName)
+	if value == "" {
+		return nil, nil
+	}
+	if len(value) > 1 && value[0] == '/' && value[len(value)-1] == '/' {
+		re, err := regexp.Compile("^(?:" + value[1:len(value)-1] + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression for option %s: %s", optionName, err)
+		}
+		return re.MatchString, nil
+	}
+	patterns := dir.Config.GetSlice(optionName, ',', true)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid pattern %q for option %s: %s", pattern, optionName, err)
+		}
+	}
+	return func(name string) bool {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSchemaNameTemplate(t *testing.T) {
+	getDir := func(template, environment string) *Dir {
+		return &Dir{
+			Path:    "/tmp/dummydir",
+			Config:  getConfig(map[string]string{"schema-name-template": template, "schema": "app_shard3,app_shard4"}),
+			section: environment,
+		}
+	}
+
+	// Without a template, names map to themselves
+	dir := getDir("", "staging")
+	if actual, err := dir.ActualSchemaName("app_shard3"); err != nil || actual != "app_shard3" {
+		t.Errorf("Unexpected result from ActualSchemaName: %q, %v", actual, err)
+	}
+	if logical, ok, err := dir.LogicalSchemaName("app_shard3"); err != nil || !ok || logical != "app_shard3" {
+		t.Errorf("Unexpected result from LogicalSchemaName: %q, %t, %v", logical, ok, err)
+	}
+
+	dir = getDir("{SCHEMA}_{environment}", "staging")
+	if actual, err := dir.ActualSchemaName("app_shard3"); err != nil || actual != "app_shard3_staging" {
+		t.Errorf("Unexpected result from ActualSchemaName: %q, %v", actual, err)
+	}
+	if names, err := dir.SchemaNames(nil); err != nil || len(names) != 2 || names[0] != "app_shard3_staging" || names[1] != "app_shard4_staging" {
+		t.Errorf("Unexpected result from SchemaNames: %v, %v", names, err)
+	}
+	expectLogical := map[string]string{
+		"app_shard3_staging": "app_shard3",
+		"a.b_staging":        "a.b",
+		"app_shard3":         "",
+		"app_staging_shard3": "",
+		"_staging":           "",
+	}
+	for actual, expected := range expectLogical {
+		logical, ok, err := dir.LogicalSchemaName(actual)
+		if err != nil {
+			t.Errorf("Unexpected error from LogicalSchemaName(%q): %s", actual, err)
+		} else if ok != (expected != "") || logical != expected {
+			t.Errorf("Expected LogicalSchemaName(%q) to return %q, instead found %q, %t", actual, expected, logical, ok)
+		}
+	}
+
+	// Round trip, with the environment in the middle of the name
+	dir = getDir("app_{ENVIRONMENT}_{SCHEMA}", "staging")
+	if actual, err := dir.ActualSchemaName("shard3"); err != nil || actual != "app_staging_shard3" {
+		t.Errorf("Unexpected result from ActualSchemaName: %q, %v", actual, err)
+	} else if logical, ok, err := dir.LogicalSchemaName(actual); err != nil || !ok || logical != "shard3" {
+		t.Errorf("Unexpected result from LogicalSchemaName: %q, %t, %v", logical, ok, err)
+	}
+
+	for _, template := range []string{"{ENVIRONMENT}_app", "{SCHEMA}_{SCHEMA}", "{SCHEMA}_{HOST}"} {
+		dir = getDir(template, "staging")
+		if _, err := dir.ActualSchemaName("app"); err == nil {
+			t.Errorf("Expected error from ActualSchemaName with template %q, but no error returned", template)
+		}
+		if _, _, err := dir.LogicalSchemaName("app"); err == nil {
+			t.Errorf("Expected error from LogicalSchemaName with template %q, but no error returned", template)
+		}
+	}
+}
+
+func TestSchemaIncluded(t *testing.T) {
+	getDir := func(include, exclude string) *Dir {
+		return &Dir{
+			Path:    "/tmp/dummydir",
+			Config:  getConfig(map[string]string{"include-schemas": include, "exclude-schemas": exclude}),
+			section: "production",
+		}
+	}
+	assertIncluded := func(dir *Dir, name string, expected bool) {
+		t.Helper()
+		if included, err := dir.SchemaIncluded(name); err != nil {
+			t.Errorf("Unexpected error from SchemaIncluded(%q): %s", name, err)
+		} else if included != expected {
+			t.Errorf("Expected SchemaIncluded(%q) to return %t, instead found %t", name, expected, included)
+		}
+	}
+
+	dir := getDir("", "")
+	assertIncluded(dir, "anything", true)
+
+	dir = getDir("app_*,billing", "app_*_old")
+	assertIncluded(dir, "app_shard1", true)
+	assertIncluded(dir, "billing", true)
+	assertIncluded(dir, "billing2", false)
+	assertIncluded(dir, "app_shard1_old", false)
+	assertIncluded(dir, "other", false)
+
+	dir = getDir("/^app_shard[0-9]+$/", "/.*shard1.*/")
+	assertIncluded(dir, "app_shard2", true)
+	assertIncluded(dir, "app_shard10", false)
+	assertIncluded(dir, "app_shardx", false)
+
+	// Regular expressions must match the entire name, even without ^ or $
+	dir = getDir("/app|billing/", "")
+	assertIncluded(dir, "app", true)
+	assertIncluded(dir, "billing", true)
+	assertIncluded(dir, "myapp_x", false)
+	assertIncluded(dir, "app_x", false)
+
+	for _, dir := range []*Dir{getDir("/app_(/", ""), getDir("", "app_[")} {
+		if _, err := dir.SchemaIncluded("app"); err == nil {
+			t.Error("Expected error from SchemaIncluded with invalid pattern, but no error returned")
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// This file contains logic for detecting sharded schemas during `skeema init
+// --group-shards`: schemas whose names differ only in a numeric suffix, and
+// which have identical table structure, are placed in a single directory
+// instead of one directory per schema.
+
+var reShardSuffix = regexp.MustCompile(`^(.*?)[_-]?\d+$`)
+
+// shardGroup represents a set of schemas which share a common name prefix.
+// Schemas in the group with the majority fingerprint are placed together in a
+// single directory; any others are considered deviants.
+type shardGroup struct {
+	DirName     string
+	SchemaNames []string
+	Deviants    []string
+}
+
+// shardPrefix returns the portion of name preceding its numeric suffix, along
+// with any separating underscore or hyphen. If name does not end in a number,
+// or consists only of a number, false is returned.
+func shardPrefix(name string) (string, bool) {
+	matches := reShardSuffix.FindStringSubmatch(name)
+	if matches == nil || matches[1] == "" {
+		return "", false
+	}
+	return matches[1], true
+}
+
+// schemaFingerprint returns a hash of the schema's default character set and
+// collation, and the CREATE TABLE statements of its tables. Next
+// auto-increment values are not included, since these naturally vary between
+// shards. Soft-dropped tables are also excluded, since init does not write
+// files for them.
+func schemaFingerprint(s *tengo.Schema) (string, error) {
+	tables, err := s.Tables()
+	if err != nil {
+		return "", err
+	}
+	createStatements := make([]string, 0, len(tables))
+	for _, t := range tables {
+		if isSoftDropped(t.Name) {
+			continue
+		}
+		createStmt, _ := tengo.ParseCreateAutoInc(t.CreateStatement())
+		createStatements = append(createStatements, createStmt)
+	}
+	sort.Strings(createStatements)
+
+	h := sha1.New()
+	io.WriteString(h, fmt.Sprintf("%s %s\n", s.CharSet, s.Collation))
+	for _, createStmt := range createStatements {
+		io.WriteString(h, createStmt)
+		io.WriteString(h, "\n")
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// groupShards groups the supplied schema names, which are mapped to their
+// fingerprints, by common name prefix. A prefix only produces a group if a
+// single fingerprint is shared by at least two schemas, and by more than half
+// of the schemas with that prefix; schemas with any other fingerprint are
+// considered deviants. Each group's directory is named after
+// its prefix, unless that would conflict with the name of another schema, in
+// which case a "-shards" suffix is added. Groups are returned sorted by
+// directory name.
+func groupShards(fingerprints map[string]string) []shardGroup {
+	names := make([]string, 0, len(fingerprints))
+	for name := range fingerprints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	byPrefix := make(map[string][]string)
+	var prefixes []string
+	for _, name := range names {
+		if prefix, ok := shardPrefix(name); ok {
+			if byPrefix[prefix] == nil {
+				prefixes = append(prefixes, prefix)
+			}
+			byPrefix[prefix] = append(byPrefix[prefix], name)
+		}
+	}
+
+	var groups []shardGroup
+	for _, prefix := range prefixes {
+		members := byPrefix[prefix]
+		counts := make(map[string]int)
+		for _, name := range members {
+			counts[fingerprints[name]]++
+		}
+		var majority string
+		for fingerprint, count := range counts {
+			if count*2 > len(members) {
+				majority = fingerprint
+			}
+		}
+		if majority == "" || counts[majority] < 2 {
+			continue
+		}
+		group := shardGroup{DirName: prefix}
+		if _, conflict := fingerprints[prefix]; conflict {
+			group.DirName = prefix + "-shards"
+		}
+		for _, name := range members {
+			if fingerprints[name] == majority {
+				group.SchemaNames = append(group.SchemaNames, name)
+			} else {
+				group.Deviants = append(group.Deviants, name)
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// String returns the group's schema names as a comma-separated list, suitable
+// for use as a value of the schema option.
+func (group shardGroup) String() string {
+	return strings.Join(group.SchemaNames, ",")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShardPrefix(t *testing.T) {
+	cases := map[string]string{
+		"app_001":   "app",
+		"app-12":    "app",
+		"shard3":    "shard",
+		"app1_2":    "app1",
+		"app_v2_07": "app_v2",
+		"app":       "",
+		"123":       "",
+		"app_2b":    "",
+	}
+	for name, expected := range cases {
+		prefix, ok := shardPrefix(name)
+		if prefix != expected || ok != (expected != "") {
+			t.Errorf("Unexpected result from shardPrefix(%q): %q, %t", name, prefix, ok)
+		}
+	}
+}
+
+func TestGroupShards(t *testing.T) {
+	fingerprints := map[string]string{
+		"app_001":  "aaa",
+		"app_002":  "aaa",
+		"app_003":  "bbb",
+		"app_004":  "aaa",
+		"app":      "ccc",
+		"users1":   "ddd",
+		"users2":   "eee",
+		"metrics":  "fff",
+		"events_1": "ggg",
+		"events_2": "hhh",
+		"events_3": "hhh",
+	}
+	expected := []shardGroup{
+		{DirName: "app-shards", SchemaNames: []string{"app_001", "app_002", "app_004"}, Deviants: []string{"app_003"}},
+		{DirName: "events", SchemaNames: []string{"events_2", "events_3"}, Deviants: []string{"events_1"}},
+	}
+	groups := groupShards(fingerprints)
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Unexpected result from groupShards: %+v", groups)
+	}
+	if groups[0].String() != "app_001,app_002,app_004" {
+		t.Errorf("Unexpected result from shardGroup.String(): %q", groups[0].String())
+	}
+
+	// Without a fingerprint shared by more than half of a prefix's schemas, no
+	// group is produced
+	for _, fingerprints := range []map[string]string{
+		{"s1": "x", "s2": "y", "s3": "x", "s4": "y"},
+		{"s1": "x", "s2": "x", "s3": "y", "s4": "y", "s5": "z"},
+		{"s1": "x", "s2": "x", "s3": "y", "s4": "z", "s5": "w"},
+	} {
+		if groups := groupShards(fingerprints); len(groups) > 0 {
+			t.Errorf("Expected groupShards(%v) to return no groups, instead found %+v", fingerprints, groups)
+		}
+	}
+}
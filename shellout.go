@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -109,15 +110,20 @@ func NewShellOut(command, printableCommand string) *ShellOut {
 // The following variables are supplied as-is from the dir's configuration:
 //   {USER}, {PASSWORD}, {SCHEMA}, {HOST}, {PORT}
 //
+// If the password-wrapper option is set, {PASSWORD} is instead the wrapper's
+// output for the USER, HOST, and PORT being interpolated. In this case it is
+// always hidden when the command-line is printed, just like {PASSWORDX}.
+//
 // These additional variables are always set; see function source code:
-//   {PASSWORDX}, {ENVIRONMENT}, {DIRNAME}, {DIRPATH}, {CONNOPTS}
+//   {PASSWORDX}, {ENVIRONMENT}, {DIRNAME}, {DIRPATH}, {CONNOPTS}, {SSLMODE},
+//   {SSLCA}, {SSLCERT}, {SSLKEY}
 //
 // Vars are case-insensitive, but all-caps is recommended for visual reasons.
 // If any unknown variable is contained in the command string, a non-nil error
 // will be returned and the unknown variable will not be interpolated.
 func NewInterpolatedShellOut(command string, dir *Dir, extra map[string]string) (*ShellOut, error) {
 	var err error
-	values := make(map[string]string, 7+len(extra))
+	values := make(map[string]string, 11+len(extra))
 
 	asis := []string{"user", "password", "schema", "host", "port"}
 	for _, name := range asis {
@@ -132,6 +138,19 @@ func NewInterpolatedShellOut(command string, dir *Dir, extra map[string]string)
 		}
 	}
 
+	// If password-wrapper is in use, PASSWORD is the wrapper's output for the
+	// host and port being connected to. Since this may require running the
+	// wrapper, it is only done if the command actually uses the password.
+	upperCommand := strings.ToUpper(command)
+	_, extraPassword := extra["PASSWORD"]
+	var wrapperPassword bool
+	if dir.Config.Changed("password-wrapper") && !extraPassword && strings.Contains(upperCommand, "{PASSWORD") {
+		wrapperPassword = true
+		if values["PASSWORD"], err = wrapperPasswordForShellOut(dir, values, extra); err != nil {
+			return nil, err
+		}
+	}
+
 	// PASSWORDX works like PASSWORD, but is hidden when the command-line is printed
 	values["PASSWORDX"] = values["PASSWORD"]
 
@@ -149,6 +168,16 @@ func NewInterpolatedShellOut(command string, dir *Dir, extra map[string]string)
 		return nil, err
 	}
 
+	// SSL options are supplied so that wrapper commands can use the same TLS
+	// settings as Skeema's own connections. SSLMODE reflects the effective mode,
+	// which may be implied by the other SSL options.
+	if values["SSLMODE"], err = dir.SSLMode(); err != nil {
+		return nil, err
+	}
+	values["SSLCA"] = dir.Config.Get("ssl-ca")
+	values["SSLCERT"] = dir.Config.Get("ssl-cert")
+	values["SSLKEY"] = dir.Config.Get("ssl-key")
+
 	// Add in extras *after*, to allow them to override previous vars if desired
 	for name, val := range extra {
 		values[strings.ToUpper(name)] = val
@@ -158,7 +187,7 @@ func NewInterpolatedShellOut(command string, dir *Dir, extra map[string]string)
 	replacer := func(input string) string {
 		input = strings.ToUpper(input[1 : len(input)-1])
 		if value, ok := values[input]; ok {
-			if input == "PASSWORDX" && suppressPassword {
+			if suppressPassword && (input == "PASSWORDX" || (input == "PASSWORD" && wrapperPassword)) {
 				return strings.Repeat("X", len(value))
 			}
 			return escapeVarValue(value)
@@ -168,7 +197,7 @@ func NewInterpolatedShellOut(command string, dir *Dir, extra map[string]string)
 	}
 
 	result := varPlaceholder.ReplaceAllStringFunc(command, replacer)
-	if strings.Contains(strings.ToUpper(command), "{PASSWORDX}") {
+	if strings.Contains(upperCommand, "{PASSWORDX}") || (wrapperPassword && strings.Contains(upperCommand, "{PASSWORD}")) {
 		suppressPassword = true
 		resultWithoutPassword := varPlaceholder.ReplaceAllStringFunc(command, replacer)
 		return NewShellOut(result, resultWithoutPassword), err
@@ -176,6 +205,28 @@ func NewInterpolatedShellOut(command string, dir *Dir, extra map[string]string)
 	return NewShellOut(result, result), err
 }
 
+// wrapperPasswordForShellOut returns the password-wrapper's output for the
+// USER, HOST, and PORT that a shellout will connect to, based on the dir's
+// configuration overridden by any values in extra. If HOST and PORT refer to
+// the local end of an SSH tunnel, the remote database host and port are used
+// instead, so that the password matches the one used for Skeema's own
+// connection.
+func wrapperPasswordForShellOut(dir *Dir, values, extra map[string]string) (string, error) {
+	lookup := func(name string) string {
+		if value, ok := extra[name]; ok {
+			return value
+		}
+		return values[name]
+	}
+	port, _ := strconv.Atoi(lookup("PORT"))
+	if port == 0 {
+		port = dir.Config.GetIntOrDefault("port")
+	}
+	host, port := tunnelRemoteHostPort(lookup("HOST"), port)
+	password, _, err := dir.password(lookup("USER"), host, port)
+	return password, err
+}
+
 // escapeVarValue takes a string, and wraps it in single-quotes so that it will
 // be interpretted as a single arg in a shell-out command line. If the value
 // already contained any single-quotes, they will be escaped in a way that will
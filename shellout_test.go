@@ -1,9 +1,14 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/skeema/mycli"
 )
 
 func TestRunCaptureSplit(t *testing.T) {
@@ -29,7 +34,7 @@ func TestRunCaptureSplit(t *testing.T) {
 
 func TestNewInterpolatedShellOut(t *testing.T) {
 	getDir := func(path string, pairs ...string) *Dir {
-		optValues := make(map[string]string)
+		optValues := map[string]string{"ssl-mode": "", "ssl-ca": "", "ssl-cert": "", "ssl-key": "", "password-wrapper": ""}
 		for _, pair := range pairs {
 			tokens := strings.SplitN(pair, "=", 2)
 			optValues[tokens[0]] = tokens[1]
@@ -57,6 +62,12 @@ func TestNewInterpolatedShellOut(t *testing.T) {
 	assertShellOut("/bin/echo {HOST} {SCHEMA} {user} {PASSWORD} {DirName} {DIRPATH}", "/bin/echo ahost aschema someone  someschema /var/schemas/somehost/someschema")
 	assertShellOut("/bin/echo {HOST} {SOMETHING}", "/bin/echo 'overridden value' new_value", "host=overridden value", "something=new_value")
 	assertShellOut("/bin/echo {connopts}", `/bin/echo 'sql_mode='"'"'STRICT_ALL_TABLES,ALLOW_INVALID_DATES'"'"''`)
+	assertShellOut("/bin/echo {SSLMODE}{SSLCA}", "/bin/echo ")
+
+	dir = getDir("/var/schemas/somehost/someschema", "host=ahost", "schema=aschema", "user=someone", "password=", "port=3306", "connect-options=", "ssl-ca=/etc/ssl/ca.pem")
+	assertShellOut("/bin/echo {SSLMODE} {SSLCA} {SSLCERT}", "/bin/echo VERIFY_CA /etc/ssl/ca.pem ")
+	dir = getDir("/var/schemas/somehost/someschema", "host=ahost", "schema=aschema", "user=someone", "password=", "port=3306", "connect-options=", "ssl-mode=verify_identity", "ssl-cert=/etc/ssl/client-cert.pem", "ssl-key=/etc/ssl/client-key.pem")
+	assertShellOut("/bin/echo {SSLMODE} {SSLCERT} {SSLKEY}", "/bin/echo VERIFY_IDENTITY /etc/ssl/client-cert.pem /etc/ssl/client-key.pem")
 
 	dir = getDir("/var/schemas/somehost/someschema", "host=ahost", "schema=aschema", "user=someone", "password=SuPeRsEcReT", "port=3306", "connect-options=")
 	assertShellOutHidePW := func(command, expected, expectedOutput string) {
@@ -108,3 +119,63 @@ func TestEscapeVarValue(t *testing.T) {
 		}
 	}
 }
+
+func TestNewInterpolatedShellOutPasswordWrapper(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	countFile := filepath.Join(tmpdir, "count")
+
+	cmd := mycli.NewCommand("test", "1.0", "this is for testing", nil)
+	AddGlobalOptions(cmd)
+	cli := &mycli.CommandLine{
+		Command: cmd,
+	}
+	optionValues := map[string]string{
+		"host":             "pwwrapper3.db.host:3307",
+		"user":             "someone",
+		"password":         "ignored",
+		"password-wrapper": "echo {HOST} >> " + countFile + "; /usr/bin/printf '{USER}-{HOST}-{PORT}\\n'",
+	}
+	dir := &Dir{
+		Path:    "/tmp/dummydir",
+		Config:  mycli.NewConfig(cli, dummySource(optionValues)),
+		section: "production",
+	}
+
+	// Commands not using the password should not run the password-wrapper
+	if _, err := NewInterpolatedShellOut("/bin/echo {HOST}", dir, nil); err != nil {
+		t.Fatalf("Unexpected error from NewInterpolatedShellOut: %s", err)
+	}
+	if _, err := os.Stat(countFile); err == nil {
+		t.Error("Expected password-wrapper not to be run, but it was")
+	}
+
+	extras := map[string]string{"HOST": "pwwrapper3.db.host", "PORT": "3307"}
+	expected := "mysql -h pwwrapper3.db.host -P 3307 -psomeone-pwwrapper3.db.host-3307"
+	expectedPrintable := "mysql -h pwwrapper3.db.host -P 3307 -p" + strings.Repeat("X", len("someone-pwwrapper3.db.host-3307"))
+	for n := 0; n < 2; n++ {
+		s, err := NewInterpolatedShellOut("mysql -h {HOST} -P {PORT} -p{PASSWORDX}", dir, extras)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewInterpolatedShellOut: %s", err)
+		} else if s.Command != expected || s.String() != expectedPrintable {
+			t.Errorf("Unexpected result from NewInterpolatedShellOut: %q / %q", s.Command, s.String())
+		}
+	}
+	// PASSWORD obtained from password-wrapper should also be hidden when printed
+	if s, err := NewInterpolatedShellOut("mysql -p{PASSWORD}", dir, extras); err != nil || s.Command != "mysql -psomeone-pwwrapper3.db.host-3307" || s.String() != "mysql -p"+strings.Repeat("X", len("someone-pwwrapper3.db.host-3307")) {
+		t.Errorf("Unexpected result from NewInterpolatedShellOut: %+v, %v", s, err)
+	}
+
+	// The wrapper's output should be cached, and shared with Instances
+	if instances, err := dir.Instances(); err != nil || len(instances) != 1 || instances[0].Password != "someone-pwwrapper3.db.host-3307" {
+		t.Errorf("Unexpected result from Instances: %+v, %v", instances, err)
+	}
+	if contents, err := ioutil.ReadFile(countFile); err != nil {
+		t.Errorf("Unable to read %s: %s", countFile, err)
+	} else if string(contents) != "pwwrapper3.db.host\n" {
+		t.Errorf("Expected password-wrapper to be run once, instead found output %q", contents)
+	}
+}
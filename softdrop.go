@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/skeema/mycli"
+	"github.com/skeema/tengo"
+)
+
+// This file contains logic for "soft-dropping" tables: rather than running
+// DROP TABLE, the table is renamed with a timestamped prefix, optionally
+// moving it into a separate trash schema. Soft-dropped tables may later be
+// restored with `skeema restore-dropped`, or permanently removed with
+// `skeema purge-dropped`.
+
+const (
+	softDropPrefix     = "_dropped_"
+	softDropTimeFormat = "20060102150405"
+	maxIdentifierLen   = 64
+	softDropHashLen    = 9 // underscore and 8 hex digits, see shortenSoftDropTable
+)
+
+var reSoftDropped = regexp.MustCompile(`^_dropped_(\d{14})_(.+)$`)
+
+// softDroppedTable represents a table that was previously soft-dropped.
+type softDroppedTable struct {
+	Name         string    // current name of the soft-dropped table
+	SchemaName   string    // schema currently containing the soft-dropped table
+	OriginalName string    // name of the table prior to being soft-dropped; may be shortened, see softDropName
+	DroppedAt    time.Time // time at which the table was soft-dropped
+}
+
+// isCopyOf returns true if sdt is a soft-dropped copy of the named table. This
+// takes into account names that were shortened by softDropName.
+func (sdt *softDroppedTable) isCopyOf(tableName string) bool {
+	if sdt.OriginalName == tableName {
+		return true
+	}
+	return len(tableName) > len(sdt.OriginalName) && sdt.OriginalName == shortenSoftDropTable(tableName, len(sdt.OriginalName))
+}
+
+// softDropTrashSchema returns the name of the schema that tables from
+// schemaName are soft-dropped into.
+func softDropTrashSchema(target *Target, schemaName string) string {
+	if trash := target.Dir.Config.Get("soft-drop-schema"); trash != "" {
+		return trash
+	}
+	return schemaName
+}
+
+// isSoftDropSchemaName returns true if name is the configured soft-drop-schema.
+// This schema should never be diffed or pushed to, nor exported to the
+// filesystem.
+func isSoftDropSchemaName(cfg *mycli.Config, name string) bool {
+	trash := cfg.Get("soft-drop-schema")
+	return trash != "" && name == trash
+}
+
+// softDropName returns the new name for a table being soft-dropped from
+// schemaName into trashSchemaName at the supplied time. When moving into a
+// separate trash schema, the original schema name is included, so that tables
+// from multiple schemas may share a single trash schema. If the result would
+// exceed the maximum identifier length, the table name portion is truncated
+// and suffixed with a hash of the full table name; restore-dropped recomputes
+// this to recognize the table.
+func softDropName(schemaName, trashSchemaName, tableName string, when time.Time) (string, error) {
+	prefix := fmt.Sprintf("%s%s_", softDropPrefix, when.UTC().Format(softDropTimeFormat))
+	if trashSchemaName != schemaName {
+		prefix = fmt.Sprintf("%s%s.", prefix, schemaName)
+	}
+	room := maxIdentifierLen - len(prefix)
+	if len(tableName) <= room {
+		return prefix + tableName, nil
+	}
+	if room <= softDropHashLen {
+		return "", fmt.Errorf("Unable to soft-drop table %s: schema name %s is too long to be included in the new name", tableName, schemaName)
+	}
+	return prefix + shortenSoftDropTable(tableName, room), nil
+}
+
+// shortenSoftDropTable deterministically shortens tableName to at most length
+// bytes, by truncating it and appending a hash of the full name. Truncation
+// never splits a multi-byte character.
+func shortenSoftDropTable(tableName string, length int) string {
+	n := length - softDropHashLen
+	for n > 0 && !utf8.RuneStart(tableName[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s_%08x", tableName[:n], crc32.ChecksumIEEE([]byte(tableName)))
+}
+
+// softDropStatement returns a RENAME TABLE statement which soft-drops the
+// supplied table on the target, in lieu of a DROP TABLE.
+func softDropStatement(target *Target, table *tengo.Table, when time.Time) (string, error) {
+	schemaName := target.SchemaFromDir.Name
+	trashSchemaName := softDropTrashSchema(target, schemaName)
+	if trashSchemaName != schemaName && !target.Instance.HasSchema(trashSchemaName) {
+		return "", fmt.Errorf("Unable to soft-drop table %s: soft-drop-schema %s does not exist on %s", table.Name, trashSchemaName, target.Instance)
+	}
+	newName, err := softDropName(schemaName, trashSchemaName, table.Name, when)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("RENAME TABLE %s.%s TO %s.%s",
+		tengo.EscapeIdentifier(schemaName), tengo.EscapeIdentifier(table.Name),
+		tengo.EscapeIdentifier(trashSchemaName), tengo.EscapeIdentifier(newName)), nil
+}
+
+// isSoftDropped returns true if the table name indicates it was soft-dropped.
+func isSoftDropped(tableName string) bool {
+	return reSoftDropped.MatchString(tableName)
+}
+
+// parseSoftDropped examines a table name in the supplied schema. If the table
+// was soft-dropped from originalSchemaName, a non-nil *softDroppedTable is
+// returned. Otherwise, nil is returned.
+func parseSoftDropped(schemaName, tableName, originalSchemaName string) *softDroppedTable {
+	matches := reSoftDropped.FindStringSubmatch(tableName)
+	if matches == nil {
+		return nil
+	}
+	when, err := time.ParseInLocation(softDropTimeFormat, matches[1], time.UTC)
+	if err != nil {
+		return nil
+	}
+	original := matches[2]
+	if schemaName != originalSchemaName {
+		prefix := originalSchemaName + "."
+		if !strings.HasPrefix(original, prefix) {
+			return nil
+		}
+		original = strings.TrimPrefix(original, prefix)
+	}
+	return &softDroppedTable{
+		Name:         tableName,
+		SchemaName:   schemaName,
+		OriginalName: original,
+		DroppedAt:    when,
+	}
+}
+
+// findSoftDropped returns all tables on the target's instance that were
+// soft-dropped from the target's schema.
+func findSoftDropped(target *Target) ([]*softDroppedTable, error) {
+	schemaName := target.SchemaFromDir.Name
+	trashSchemaName := softDropTrashSchema(target, schemaName)
+	db, err := target.Instance.Connect("information_schema", "")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	err = db.Select(&names, `
+		SELECT  table_name
+		FROM    tables
+		WHERE   table_schema = ? AND table_name LIKE ?`,
+		trashSchemaName, `\_dropped\_%`)
+	if err != nil {
+		return nil, err
+	}
+	var result []*softDroppedTable
+	for _, name := range names {
+		if sdt := parseSoftDropped(trashSchemaName, name, schemaName); sdt != nil {
+			result = append(result, sdt)
+		}
+	}
+	return result, nil
+}
+
+// filterSoftDropped removes CREATE TABLE and DROP TABLE statements from diff,
+// if they refer to soft-dropped tables. Soft-dropped tables left in their
+// original schema are never represented in the filesystem, and should not be
+// considered a difference.
+func filterSoftDropped(diff *tengo.SchemaDiff) {
+	filtered := diff.TableDiffs[:0]
+	for _, td := range diff.TableDiffs {
+		switch td := td.(type) {
+		case tengo.CreateTable:
+			if isSoftDropped(td.Table.Name) {
+				continue
+			}
+		case tengo.DropTable:
+			if isSoftDropped(td.Table.Name) {
+				continue
+			}
+		}
+		filtered = append(filtered, td)
+	}
+	diff.TableDiffs = filtered
+}
+
+// parseAge parses a duration string such as "36h" or "7d". In addition to the
+// units accepted by time.ParseDuration, a "d" suffix may be used for days.
+func parseAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(value, "d"), 64)
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("Invalid duration \"%s\"", value)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Invalid duration \"%s\"", value)
+	}
+	return d, nil
+}
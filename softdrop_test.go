@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSoftDropName(t *testing.T) {
+	when := time.Date(2018, 6, 14, 18, 30, 0, 0, time.UTC)
+	assertName := func(schemaName, trashSchemaName, tableName, expected string) {
+		t.Helper()
+		name, err := softDropName(schemaName, trashSchemaName, tableName, when)
+		if err != nil {
+			t.Errorf("Unexpected error from softDropName: %s", err)
+		} else if name != expected {
+			t.Errorf("Expected softDropName to return %s, instead found %s", expected, name)
+		}
+		sdt := parseSoftDropped(trashSchemaName, name, schemaName)
+		if sdt == nil {
+			t.Fatalf("Expected parseSoftDropped to parse %s, but it returned nil", name)
+		}
+		if sdt.OriginalName != tableName || !sdt.DroppedAt.Equal(when) || sdt.SchemaName != trashSchemaName {
+			t.Errorf("parseSoftDropped returned unexpected result %+v", *sdt)
+		}
+	}
+	assertName("product", "product", "widgets", "_dropped_20180614183000_widgets")
+	assertName("product", "trash", "widgets", "_dropped_20180614183000_product.widgets")
+	assertName("product", "product", "_dropped_20170101000000_x", "_dropped_20180614183000__dropped_20170101000000_x")
+
+	assertName("product", "product", strings.Repeat("x", 40), "_dropped_20180614183000_"+strings.Repeat("x", 40))
+
+	// Names that would be too long are truncated and suffixed with a hash, and
+	// must still be recognized as copies of the original table
+	longNames := map[string]string{
+		"product": strings.Repeat("x", 41),
+		"trash":   strings.Repeat("y", 64),
+	}
+	for trashSchemaName, tableName := range longNames {
+		name, err := softDropName("product", trashSchemaName, tableName, when)
+		if err != nil {
+			t.Fatalf("Unexpected error from softDropName: %s", err)
+		} else if len(name) > maxIdentifierLen {
+			t.Errorf("Expected softDropName to return a name of at most %d characters, instead found %s", maxIdentifierLen, name)
+		} else if again, _ := softDropName("product", trashSchemaName, tableName, when); again != name {
+			t.Errorf("Expected softDropName to be deterministic, instead found %s vs %s", name, again)
+		}
+		sdt := parseSoftDropped(trashSchemaName, name, "product")
+		if sdt == nil {
+			t.Fatalf("Expected parseSoftDropped to parse %s, but it returned nil", name)
+		}
+		if !sdt.isCopyOf(tableName) || sdt.isCopyOf(tableName[1:]) || sdt.isCopyOf(tableName+"z") {
+			t.Errorf("Unexpected result from isCopyOf for %+v", *sdt)
+		}
+	}
+	if name, err := softDropName("product", "product", "é"+strings.Repeat("é", 40), when); err != nil || !utf8.ValidString(name) {
+		t.Errorf("Unexpected result from softDropName for multi-byte name: %q, %v", name, err)
+	}
+	if _, err := softDropName(strings.Repeat("s", 40), "trash", "widgets", when); err == nil {
+		t.Error("Expected softDropName to return error for excessively long schema name, but it did not")
+	}
+	if sdt := parseSoftDropped("trash", "_dropped_20180614183000_other.widgets", "product"); sdt != nil {
+		t.Errorf("Expected parseSoftDropped to ignore table from another schema, instead found %+v", *sdt)
+	}
+	for _, name := range []string{"widgets", "_dropped_2018061418300_widgets", "_dropped_20181399999999_widgets"} {
+		if sdt := parseSoftDropped("product", name, "product"); sdt != nil {
+			t.Errorf("Expected parseSoftDropped to return nil for %s, instead found %+v", name, *sdt)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	cases := map[string]time.Duration{
+		"7d":    7 * 24 * time.Hour,
+		"0.5d":  12 * time.Hour,
+		"36h":   36 * time.Hour,
+		"1h30m": 90 * time.Minute,
+		"0s":    0,
+	}
+	for input, expected := range cases {
+		if actual, err := parseAge(input); err != nil {
+			t.Errorf("Unexpected error from parseAge(\"%s\"): %s", input, err)
+		} else if actual != expected {
+			t.Errorf("Expected parseAge(\"%s\") to return %s, instead found %s", input, expected, actual)
+		}
+	}
+	for _, input := range []string{"", "7", "d", "-2d", "-1h", "seven days"} {
+		if _, err := parseAge(input); err == nil {
+			t.Errorf("Expected parseAge(\"%s\") to return an error, but it did not", input)
+		}
+	}
+}
+
+func TestIsSoftDropSchemaName(t *testing.T) {
+	cfg := getConfig(map[string]string{"soft-drop-schema": ""})
+	if isSoftDropSchemaName(cfg, "trash") || isSoftDropSchemaName(cfg, "") {
+		t.Error("Expected no schema to be considered the soft-drop-schema when the option is blank")
+	}
+	cfg = getConfig(map[string]string{"soft-drop-schema": "trash"})
+	if !isSoftDropSchemaName(cfg, "trash") {
+		t.Error("Expected trash to be considered the soft-drop-schema")
+	}
+	if isSoftDropSchemaName(cfg, "product") {
+		t.Error("Expected product not to be considered the soft-drop-schema")
+	}
+}
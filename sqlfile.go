@@ -18,6 +18,9 @@ import (
 // [4] is any text after the table body -- we ignore this
 var reParseCreate = regexp.MustCompile(`(?is)^(.*)\s*create\s+table\s+(?:if\s+not\s+exists\s+)?` + "`?([^\\s`]+)`?" + `\s+([^;]+);?\s*(.*)$`)
 
+// Regexp for finding the start of a CREATE TABLE statement
+var reFindCreate = regexp.MustCompile(`(?i)create\s+table`)
+
 // We disallow CREATE TABLE SELECT and CREATE TABLE LIKE expressions
 var reBodyDisallowed = regexp.MustCompile(`(?i)^(as\s+select|select|like|[(]\s+like)`)
 
@@ -38,13 +41,16 @@ func IsSQLFile(fi os.FileInfo) bool {
 	return true
 }
 
-// SQLFile represents a file containing a CREATE TABLE statement.
+// SQLFile represents a file containing a CREATE TABLE statement, optionally
+// preceded by annotation lines.
 type SQLFile struct {
-	Dir      *Dir
-	FileName string
-	Contents string
-	Error    error
-	Warnings []error
+	Dir         *Dir
+	FileName    string
+	Contents    string
+	Annotations []string // annotation lines, e.g. "-- skeema:allow-unsafe"
+	Error       error
+	Warnings    []error
+	tableName   string
 }
 
 // Path returns the full absolute path to a SQLFile.
@@ -69,7 +75,10 @@ func (sf *SQLFile) Read() (string, error) {
 }
 
 // Write writes the current value of sf.Contents to the file, returning the
-// number of bytes written and any error.
+// number of bytes written and any error. Annotations are written before the
+// contents. If sf.Annotations is nil, any annotations already present in the
+// file are preserved. In dry-run mode, the file is not written, and a diff of
+// the changes is displayed instead.
 func (sf *SQLFile) Write() (int, error) {
 	if !strings.HasSuffix(sf.FileName, ".sql") {
 		return 0, fmt.Errorf("Filename %s does not end in .sql extension", sf.FileName)
@@ -77,7 +86,20 @@ func (sf *SQLFile) Write() (int, error) {
 	if sf.Contents == "" {
 		return 0, fmt.Errorf("SQLFile.Write: refusing to write blank / unpopulated file contents to %s", sf.Path())
 	}
+	if sf.Annotations == nil {
+		if byteContents, err := ioutil.ReadFile(sf.Path()); err == nil {
+			sf.Annotations = parseAnnotationLines(string(byteContents))
+		}
+	}
 	value := fmt.Sprintf("%s;\n", sf.Contents)
+	if len(sf.Annotations) > 0 {
+		value = fmt.Sprintf("%s\n%s", strings.Join(sf.Annotations, "\n"), value)
+	}
+	if sf.Dir.DryRun() {
+		oldContents, _ := ioutil.ReadFile(sf.Path()) // blank if file does not exist yet
+		showFileChange(sf.Path(), string(oldContents), value)
+		return len(value), nil
+	}
 	err := ioutil.WriteFile(sf.Path(), []byte(value), 0666)
 	if err != nil {
 		return 0, err
@@ -85,8 +107,12 @@ func (sf *SQLFile) Write() (int, error) {
 	return len(value), nil
 }
 
-// Delete unlinks the file.
+// Delete unlinks the file. In dry-run mode, the file is not removed.
 func (sf *SQLFile) Delete() error {
+	if sf.Dir.DryRun() {
+		dryRunChanges++
+		return nil
+	}
 	return os.Remove(sf.Path())
 }
 
@@ -104,12 +130,17 @@ func (sf *SQLFile) validateContents() error {
 		sf.Error = fmt.Errorf("%s: cannot parse a valid CREATE TABLE statement", sf.Path())
 		return sf.Error
 	}
-	if len(matches[1]) > 0 || len(matches[4]) > 0 {
-		warning := fmt.Errorf("%s: ignoring %d chars before CREATE TABLE and %d chars after CREATE TABLE", sf.Path(), len(matches[1]), len(matches[4]))
-		sf.Warnings = append(sf.Warnings, warning)
+	sf.Annotations = parseAnnotationLines(matches[1])
+	if _, err := ParseAnnotations(sf.Annotations); err != nil {
+		sf.Error = fmt.Errorf("%s: %s", sf.Path(), err)
+		return sf.Error
 	}
-	if sf.FileName != fmt.Sprintf("%s.sql", matches[2]) {
-		warning := fmt.Errorf("%s: filename does not match table name of %s", sf.Path(), matches[2])
+	prefix := matches[1]
+	for _, line := range sf.Annotations {
+		prefix = strings.Replace(prefix, line, "", 1)
+	}
+	if prefix = strings.TrimSpace(prefix); len(prefix) > 0 || len(matches[4]) > 0 {
+		warning := fmt.Errorf("%s: ignoring %d chars before CREATE TABLE and %d chars after CREATE TABLE", sf.Path(), len(prefix), len(matches[4]))
 		sf.Warnings = append(sf.Warnings, warning)
 	}
 	if reBodyDisallowed.MatchString(matches[3]) {
@@ -117,6 +148,30 @@ func (sf *SQLFile) validateContents() error {
 		return sf.Error
 	}
 
+	sf.tableName = matches[2]
 	sf.Contents = fmt.Sprintf("CREATE TABLE %s %s", tengo.EscapeIdentifier(matches[2]), matches[3])
 	return nil
 }
+
+// TableAnnotations returns the parsed annotations of a file that has been
+// successfully read.
+func (sf *SQLFile) TableAnnotations() TableAnnotations {
+	ta, _ := ParseAnnotations(sf.Annotations) // errors already checked by Read
+	return ta
+}
+
+// parseAnnotationLines returns the annotation lines found in text, with any
+// trailing whitespace removed. Only lines preceding the CREATE TABLE statement
+// are examined.
+func parseAnnotationLines(text string) []string {
+	if loc := reFindCreate.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+	var result []string
+	for _, line := range strings.Split(text, "\n") {
+		if IsAnnotation(line) {
+			result = append(result, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	return result
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/skeema/tengo"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// This file contains logic for connecting to database hosts through an SSH
+// bastion host, based on the ssh-host, ssh-user, and ssh-key options. Each
+// tunnel is a local port forward provided in-process: connections accepted on
+// a local listener are relayed to the remote database host over an SSH client
+// connection. One SSH client connection is opened per bastion host, and shared
+// by all tunnels through that host. Tunnels are opened on first use, reused by
+// all connections to the same database host, and shut down when Skeema exits.
+
+// sshClient represents a connection to an SSH host, which may be shared by
+// multiple tunnels.
+type sshClient struct {
+	sshHost   string
+	sshPort   int
+	sshUser   string
+	sshKey    string
+	client    *ssh.Client
+	agentConn net.Conn // connection to ssh-agent, if one is in use
+}
+
+// sshTunnel represents a local port forward to a remote database host, by way
+// of an SSH host.
+type sshTunnel struct {
+	*sshClient
+	remoteHost string
+	remotePort int
+	localPort  int
+	listener   net.Listener
+}
+
+var sshTunnels struct {
+	sync.Mutex
+	clients     map[string]*sshClient
+	byKey       map[string]*sshTunnel
+	byLocalPort map[int]*sshTunnel
+}
+
+// SSHTunnel returns a tunnel to the supplied database host and port, by way of
+// the dir's ssh-host. The tunnel is opened if it is not already open; it will
+// be closed automatically upon Exit.
+func (dir *Dir) SSHTunnel(host string, port int) (*sshTunnel, error) {
+	sc := &sshClient{
+		sshUser: dir.Config.Get("ssh-user"),
+		sshKey:  dir.Config.Get("ssh-key"),
+	}
+	var err error
+	if sc.sshHost, sc.sshPort, err = tengo.SplitHostOptionalPort(dir.Config.Get("ssh-host")); err != nil {
+		return nil, err
+	}
+	if sc.sshPort == 0 {
+		sc.sshPort = 22
+	}
+	if sc.sshUser == "" {
+		sc.sshUser = defaultSSHUser()
+	}
+	tunnel := &sshTunnel{
+		sshClient:  sc,
+		remoteHost: host,
+		remotePort: port,
+	}
+	key := tunnel.String()
+
+	sshTunnels.Lock()
+	defer sshTunnels.Unlock()
+	if existing, ok := sshTunnels.byKey[key]; ok {
+		return existing, nil
+	}
+	if sshTunnels.byKey == nil {
+		sshTunnels.clients = make(map[string]*sshClient)
+		sshTunnels.byKey = make(map[string]*sshTunnel)
+		sshTunnels.byLocalPort = make(map[int]*sshTunnel)
+		handleInterrupts()
+	}
+	if existing, ok := sshTunnels.clients[sc.String()]; ok {
+		tunnel.sshClient = existing
+	} else {
+		if err := sc.connect(30 * time.Second); err != nil {
+			return nil, err
+		}
+		sshTunnels.clients[sc.String()] = sc
+	}
+	if err := tunnel.open(); err != nil {
+		return nil, err
+	}
+	sshTunnels.byKey[key] = tunnel
+	sshTunnels.byLocalPort[tunnel.localPort] = tunnel
+	return tunnel, nil
+}
+
+// String returns a description of the SSH user, host, and port, along with the
+// key file if one was specified.
+func (sc *sshClient) String() string {
+	desc := fmt.Sprintf("%s@%s", sc.sshUser, net.JoinHostPort(sc.sshHost, strconv.Itoa(sc.sshPort)))
+	if sc.sshKey != "" {
+		desc = fmt.Sprintf("%s (key %s)", desc, sc.sshKey)
+	}
+	return desc
+}
+
+// connect establishes the SSH client connection.
+func (sc *sshClient) connect(timeout time.Duration) error {
+	authMethods, err := sc.authMethods()
+	if err != nil {
+		return err
+	}
+	hostKeyCallback, err := knownHostsCallback()
+	if err != nil {
+		sc.close()
+		return err
+	}
+	config := &ssh.ClientConfig{
+		User:            sc.sshUser,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	}
+	address := net.JoinHostPort(sc.sshHost, strconv.Itoa(sc.sshPort))
+	log.Debugf("Connecting to SSH host %s", sc)
+	if sc.client, err = ssh.Dial("tcp", address, config); err != nil {
+		sc.close()
+		return fmt.Errorf("Unable to connect to SSH host %s: %s", sc, err)
+	}
+	return nil
+}
+
+// close closes the SSH client connection and ssh-agent connection, if open.
+func (sc *sshClient) close() error {
+	var err error
+	if sc.client != nil {
+		err = sc.client.Close()
+	}
+	if sc.agentConn != nil {
+		sc.agentConn.Close()
+	}
+	return err
+}
+
+// authMethods returns the methods to use for authenticating to the SSH host.
+// If ssh-key is set, only that key is used. Otherwise, any keys held by a
+// running ssh-agent are tried, followed by the default key files in ~/.ssh.
+// Passphrase-protected key files prompt for the passphrase, which requires
+// STDIN to be a TTY. Default key files are skipped if they cannot be read or
+// parsed, or if they are passphrase-protected but no prompt is possible or
+// another key is already available.
+func (sc *sshClient) authMethods() ([]ssh.AuthMethod, error) {
+	if sc.sshKey != "" {
+		signer, err := readSSHKey(sc.sshKey)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	var methods []ssh.AuthMethod
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			sc.agentConn = conn
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		} else {
+			log.Debugf("Unable to connect to ssh-agent: %s", err)
+		}
+	}
+	canPrompt := terminal.IsTerminal(int(syscall.Stdin))
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		path := filepath.Join(filepath.Clean(os.Getenv("HOME")), ".ssh", name)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			log.Debugf("Skipping SSH key %s: %s", path, err)
+			continue
+		}
+		if sshKeyEncrypted(data) && (!canPrompt || len(methods) > 0 || len(signers) > 0) {
+			log.Debugf("Skipping passphrase-protected SSH key %s", path)
+			continue
+		}
+		signer, err := parseSSHKey(path, data)
+		if err != nil {
+			log.Debugf("Skipping SSH key %s: %s", path, err)
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("No usable SSH keys found: set ssh-key, or run an ssh-agent")
+	}
+	return methods, nil
+}
+
+// readSSHKey reads and parses the private key file at path. If the key is
+// protected by a passphrase, the user is prompted to enter it.
+func readSSHKey(path string) (ssh.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read SSH key: %s", err)
+	}
+	return parseSSHKey(path, data)
+}
+
+// parseSSHKey parses data, the contents of the private key file at path. If
+// the key is protected by a passphrase, the user is prompted to enter it.
+func parseSSHKey(path string, data []byte) (signer ssh.Signer, err error) {
+	if sshKeyEncrypted(data) {
+		var passphrase string
+		passphrase, err = promptSecret(fmt.Sprintf("Enter passphrase for %s: ", path))
+		fmt.Println()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to obtain passphrase for SSH key %s: %s", path, err)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse SSH key %s: %s", path, err)
+	}
+	return signer, nil
+}
+
+// sshKeyEncrypted returns true if data is a PEM-encoded private key protected
+// by a passphrase.
+func sshKeyEncrypted(data []byte) bool {
+	block, _ := pem.Decode(data)
+	return block != nil && x509.IsEncryptedPEMBlock(block)
+}
+
+// knownHostsCallback returns a host key callback which verifies the SSH host's
+// key against ~/.ssh/known_hosts.
+func knownHostsCallback() (ssh.HostKeyCallback, error) {
+	path := filepath.Join(filepath.Clean(os.Getenv("HOME")), ".ssh", "known_hosts")
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read SSH known hosts file: %s", err)
+	}
+	return callback, nil
+}
+
+// defaultSSHUser returns the name of the current OS user, which is used if
+// ssh-user is not set.
+func defaultSSHUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// String returns a description of the tunnel, including the SSH user and host
+// and the remote database host and port.
+func (tunnel *sshTunnel) String() string {
+	return fmt.Sprintf("%s to %s", tunnel.sshClient, net.JoinHostPort(tunnel.remoteHost, strconv.Itoa(tunnel.remotePort)))
+}
+
+// open begins listening on a local port, and relays each accepted connection
+// to the remote database host via the SSH client. Since the listener remains
+// open for the lifetime of the tunnel, no other process can claim the port.
+func (tunnel *sshTunnel) open() (err error) {
+	if tunnel.listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return err
+	}
+	tunnel.localPort = tunnel.listener.Addr().(*net.TCPAddr).Port
+	go tunnel.acceptLoop()
+	log.Infof("Opened SSH tunnel %s on local port %d", tunnel, tunnel.localPort)
+	return nil
+}
+
+// acceptLoop relays connections accepted on the tunnel's listener until the
+// listener is closed.
+func (tunnel *sshTunnel) acceptLoop() {
+	remoteAddr := net.JoinHostPort(tunnel.remoteHost, strconv.Itoa(tunnel.remotePort))
+	for {
+		localConn, err := tunnel.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer localConn.Close()
+			remoteConn, err := tunnel.client.Dial("tcp", remoteAddr)
+			if err != nil {
+				log.Warnf("SSH tunnel %s unable to connect to remote host: %s", tunnel, err)
+				return
+			}
+			defer remoteConn.Close()
+			relay(localConn, remoteConn)
+		}()
+	}
+}
+
+// relay copies data in both directions between two connections, returning
+// once either direction is finished.
+func relay(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	copyConn := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go copyConn(a, b)
+	go copyConn(b, a)
+	<-done
+}
+
+// closeSSHTunnels closes all SSH tunnels and SSH client connections opened by
+// this process. It should be called prior to exiting.
+func closeSSHTunnels() {
+	sshTunnels.Lock()
+	defer sshTunnels.Unlock()
+	for key, tunnel := range sshTunnels.byKey {
+		log.Debugf("Closing SSH tunnel %s", tunnel)
+		tunnel.listener.Close()
+		delete(sshTunnels.byKey, key)
+		delete(sshTunnels.byLocalPort, tunnel.localPort)
+	}
+	for key, sc := range sshTunnels.clients {
+		if err := sc.close(); err != nil {
+			log.Warnf("Unable to cleanly close SSH connection to %s: %s", sc, err)
+		}
+		delete(sshTunnels.clients, key)
+	}
+}
+
+// InstanceRemoteHostPort returns the host and port of instance. If the
+// instance is accessed via an SSH tunnel, the remote database host and port
+// are returned, rather than the local end of the tunnel.
+func InstanceRemoteHostPort(instance *tengo.Instance) (string, int) {
+	return tunnelRemoteHostPort(instance.Host, instance.Port)
+}
+
+// tunnelRemoteHostPort returns the remote database host and port if host and
+// port refer to the local end of an SSH tunnel. Otherwise, host and port are
+// returned unchanged.
+func tunnelRemoteHostPort(host string, port int) (string, int) {
+	sshTunnels.Lock()
+	defer sshTunnels.Unlock()
+	if tunnel, ok := sshTunnels.byLocalPort[port]; ok && host == "127.0.0.1" {
+		return tunnel.remoteHost, tunnel.remotePort
+	}
+	return host, port
+}
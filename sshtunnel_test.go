@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHTunnelString(t *testing.T) {
+	tunnel := &sshTunnel{
+		sshClient: &sshClient{
+			sshHost: "bastion.example.com",
+			sshPort: 22,
+			sshUser: "deploy",
+		},
+		remoteHost: "db1.internal",
+		remotePort: 3306,
+	}
+	if actual := tunnel.String(); actual != "deploy@bastion.example.com:22 to db1.internal:3306" {
+		t.Errorf("Unexpected String() result: %s", actual)
+	}
+
+	tunnel.sshPort = 2222
+	tunnel.sshKey = "/home/deploy/.ssh/id_rsa"
+	tunnel.remoteHost = "fd00::1"
+	if actual := tunnel.String(); actual != "deploy@bastion.example.com:2222 (key /home/deploy/.ssh/id_rsa) to [fd00::1]:3306" {
+		t.Errorf("Unexpected String() result: %s", actual)
+	}
+}
+
+func TestReadSSHKey(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %s", err)
+	}
+
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}
+	keyFile := filepath.Join(tmpdir, "id_rsa")
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if sshKeyEncrypted(pem.EncodeToMemory(block)) {
+		t.Error("Expected unencrypted key to not be detected as encrypted")
+	}
+	if signer, err := readSSHKey(keyFile); err != nil {
+		t.Errorf("Unexpected error from readSSHKey: %s", err)
+	} else if signer.PublicKey().Type() != ssh.KeyAlgoRSA {
+		t.Errorf("Unexpected key type %s", signer.PublicKey().Type())
+	}
+	sc := &sshClient{sshKey: keyFile}
+	if methods, err := sc.authMethods(); err != nil || len(methods) != 1 {
+		t.Errorf("Unexpected result from authMethods: %v, %v", methods, err)
+	}
+
+	// A passphrase-protected key requires prompting, which fails since STDIN is
+	// not a TTY in tests
+	block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte("s3cret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatalf("Unable to encrypt key: %s", err)
+	}
+	if !sshKeyEncrypted(pem.EncodeToMemory(block)) {
+		t.Error("Expected encrypted key to be detected as encrypted")
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if _, err := readSSHKey(keyFile); err == nil {
+		t.Error("Expected error from readSSHKey for passphrase-protected key without TTY, but no error returned")
+	}
+
+	if _, err := readSSHKey(filepath.Join(tmpdir, "doesnt-exist")); err == nil {
+		t.Error("Expected error from readSSHKey for nonexistent file, but no error returned")
+	}
+	if err := ioutil.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if _, err := readSSHKey(keyFile); err == nil {
+		t.Error("Expected error from readSSHKey for invalid key, but no error returned")
+	}
+}
+
+func TestAuthMethodsDefaultKeys(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	if err := os.Mkdir(filepath.Join(tmpdir, ".ssh"), 0700); err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	origHome, origSock := os.Getenv("HOME"), os.Getenv("SSH_AUTH_SOCK")
+	defer func() {
+		os.Setenv("HOME", origHome)
+		os.Setenv("SSH_AUTH_SOCK", origSock)
+	}()
+	os.Setenv("HOME", tmpdir)
+	os.Unsetenv("SSH_AUTH_SOCK")
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %s", err)
+	}
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey), []byte("s3cret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatalf("Unable to encrypt key: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, ".ssh", "id_rsa"), pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+
+	// With only a passphrase-protected default key, and no TTY to prompt for it,
+	// there are no usable keys
+	sc := &sshClient{}
+	if _, err := sc.authMethods(); err == nil {
+		t.Error("Expected error from authMethods with no usable keys, but no error returned")
+	}
+
+	// Passphrase-protected and invalid default keys are skipped if another key
+	// is usable
+	block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, ".ssh", "id_ecdsa"), pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, ".ssh", "id_ed25519"), []byte("not a key"), 0600); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if methods, err := sc.authMethods(); err != nil || len(methods) != 1 {
+		t.Errorf("Unexpected result from authMethods: %v, %v", methods, err)
+	}
+}
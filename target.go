@@ -23,17 +23,72 @@ const MaxNonSkeemaDirs = 1000
 // that this dir maps to on each instance).
 type Target struct {
 	Instance           *tengo.Instance
+	Workspace          *tengo.Instance // separate instance for temp schema operations, or nil to use Instance
 	SchemaFromInstance *tengo.Schema
 	SchemaFromDir      *tengo.Schema
 	Dir                *Dir
 	Err                error
-	SQLFileErrors      map[string]*SQLFile // map of string path to *SQLFile that contains an error
-	SQLFileWarnings    []error             // slice of all warnings for Target.Dir (no need to organize by file or path)
+	SQLFileErrors      map[string]*SQLFile         // map of string path to *SQLFile that contains an error
+	SQLFileWarnings    []error                     // slice of all warnings for Target.Dir (no need to organize by file or path)
+	TableAnnotations   map[string]TableAnnotations // map of table name to annotations from its *.sql file
+	TableFiles         map[string]string           // map of table name to base name of the *.sql file containing it
+}
+
+// SQLFileForTable returns an SQLFile for the named table, without reading it.
+// If the table's CREATE TABLE was read from a file in t.Dir, that file is used,
+// regardless of its name. Otherwise, for new tables, the default file name of
+// <table>.sql is used, unless that file already contains a different table; in
+// that case a numeric suffix is added, for example <table>_2.sql.
+func (t *Target) SQLFileForTable(tableName string) SQLFile {
+	fileName, ok := t.TableFiles[tableName]
+	if !ok {
+		usedFileNames := make(map[string]bool, len(t.TableFiles))
+		for _, usedFileName := range t.TableFiles {
+			usedFileNames[usedFileName] = true
+		}
+		fileName = fmt.Sprintf("%s.sql", tableName)
+		for n := 2; usedFileNames[fileName]; n++ {
+			fileName = fmt.Sprintf("%s_%d.sql", tableName, n)
+		}
+	}
+	return SQLFile{
+		Dir:      t.Dir,
+		FileName: fileName,
+	}
+}
+
+// workspace returns the instance that should be used for the Target's temp
+// schema operations.
+func (t *Target) workspace() *tengo.Instance {
+	if t.Workspace != nil {
+		return t.Workspace
+	}
+	return t.Instance
 }
 
 // TargetGroup represents a group of Targets that all have the same Instance.
 type TargetGroup []*Target
 
+// targetsByInstanceAndSchema is a sortable slice of Targets, ordered by
+// instance and then by schema name.
+type targetsByInstanceAndSchema []*Target
+
+func (targets targetsByInstanceAndSchema) Len() int {
+	return len(targets)
+}
+
+func (targets targetsByInstanceAndSchema) Swap(i, j int) {
+	targets[i], targets[j] = targets[j], targets[i]
+}
+
+func (targets targetsByInstanceAndSchema) Less(i, j int) bool {
+	iInst, jInst := targets[i].Instance.String(), targets[j].Instance.String()
+	if iInst != jInst {
+		return iInst < jInst
+	}
+	return targets[i].SchemaFromDir.Name < targets[j].SchemaFromDir.Name
+}
+
 // TargetGroupMap stores multiple TargetGroups, properly arranged by Instance.
 type TargetGroupMap map[string]TargetGroup
 
@@ -117,30 +172,64 @@ func generateTargetsForDir(dir *Dir, targetsByInstance TargetGroupMap, firstOnly
 			targetsByInstance.AddDirError(dir, instancesErr)
 		}
 
-		// Obtain a "template" Target based on the dir's configuration and *.sql
-		// contents. This is used later for creating instance- and schema-specific
-		// Targets.
-		var template Target
-		if len(instances) > 0 {
-			template = dir.TargetTemplate(instances[0])
-
-			if template.Err == nil && fatalSQLFileErrors && len(template.SQLFileErrors) > 0 {
-				for _, sf := range template.SQLFileErrors {
-					template.Err = sf.Error
-					break // only need one element of the map, doesn't matter which one
+		// If pushing, don't run DDL on read-only replicas, unless configured to
+		// redirect to their primary instead
+		instances = resolveReplicaInstances(dir, instances, targetsByInstance)
+
+		// Obtain "template" Targets based on the dir's configuration and *.sql
+		// contents. These are used later for creating instance- and schema-specific
+		// Targets. Since SHOW CREATE TABLE output varies between server versions and
+		// flavors, a separate template is built for each distinct version series
+		// among the instances, and each instance uses the template matching its own
+		// server. (If a workspace is configured, all templates would be built there
+		// anyway, so a single template is used.)
+		templates := make(map[string]*Target)
+		var usableInstances []*tengo.Instance
+		var instanceTemplates []*Target
+		for _, inst := range instances {
+			var key string
+			if !dir.Config.Changed("workspace-host") && !dir.Config.Changed("workspace-basedir") {
+				flavor, err := instanceFlavor(inst)
+				if err != nil {
+					targetsByInstance.AddInstanceError(inst, dir, err)
+					continue
 				}
+				key = flavor.Series()
 			}
+			template := templates[key]
+			if template == nil {
+				t := dir.TargetTemplate(inst)
+				template = &t
+				templates[key] = template
+
+				if template.Err == nil && fatalSQLFileErrors && len(template.SQLFileErrors) > 0 {
+					for _, sf := range template.SQLFileErrors {
+						template.Err = sf.Error
+						break // only need one element of the map, doesn't matter which one
+					}
+				}
 
-			// If something went wrong obtaining the temp schema, record the error
-			// (without the instance, so it's clear that the entire dir is being skipped)
-			// and don't generate any instance-specific Targets for this dir.
-			if template.Err != nil {
-				targetsByInstance.AddDirError(dir, template.Err)
-				instances = instances[:0]
+				// If something went wrong obtaining the temp schema, record the error
+				// (without the instance, so it's clear that the entire dir is being skipped)
+				// and don't generate any instance-specific Targets for this dir.
+				if template.Err != nil {
+					targetsByInstance.AddDirError(dir, template.Err)
+					usableInstances = nil
+					break
+				}
+				if len(templates) > 1 {
+					log.Debugf("Using separate template for %s in %s, due to differing server version %s", inst, dir, key)
+				}
 			}
+			usableInstances = append(usableInstances, inst)
+			instanceTemplates = append(instanceTemplates, template)
 		}
 
-		for _, inst := range instances {
+		for n, inst := range usableInstances {
+			template := *instanceTemplates[n]
+			if template.Workspace != nil {
+				warnWorkspaceVersionMismatch(template.Workspace, inst)
+			}
 			schemaNames, err := dir.SchemaNames(inst)
 			if err != nil {
 				targetsByInstance.AddInstanceError(inst, dir, err)
@@ -207,14 +296,16 @@ func generateTargetsForDir(dir *Dir, targetsByInstance TargetGroupMap, firstOnly
 	return
 }
 
-// verifyDiff verifies the result of all AlterTable values found in
-// diff.TableDiffs, confirming that applying the corresponding ALTER would
-// bring a table from the version in SchemaFromInstance to the version in
-// SchemaFromDir.
+// verifyDiff verifies the result of all statements in diff, confirming that
+// applying them would bring the schema from the version in SchemaFromInstance
+// to the version in SchemaFromDir. The complete statement list, including any
+// schema-level DDL, is applied to a clone of SchemaFromInstance in the temp
+// schema. The entire resulting schema is then compared to SchemaFromDir.
 func (t *Target) verifyDiff(diff *tengo.SchemaDiff) (err error) {
 	// Populate the temp schema with a copy of the tables from SchemaFromInstance,
 	// the "before" state of the tables
-	tempSchemaName := t.Dir.Config.Get("temp-schema")
+	tempSchemaName := TempSchemaName(t.Dir.Config)
+	workspace := t.workspace()
 
 	// TODO: want to skip binlogging for all temp schema actions, if super priv available
 	var tx *sql.Tx
@@ -228,79 +319,184 @@ func (t *Target) verifyDiff(diff *tengo.SchemaDiff) (err error) {
 		}
 	}()
 
-	tempSchema, err := t.Instance.Schema(tempSchemaName)
+	tempSchema, err := workspace.Schema(tempSchemaName)
 	if err != nil {
 		return err
 	}
 	if tempSchema != nil {
 		// Attempt to drop any tables already present in tempSchema, but fail if
 		// any of them actually have 1 or more rows
-		if err := t.Instance.DropTablesInSchema(tempSchema, true); err != nil {
-			return fmt.Errorf("verifyDiff: cannot drop existing tables for %s on %s: %s", t.Dir, t.Instance, err)
+		if err := workspace.DropTablesInSchema(tempSchema, true); err != nil {
+			return fmt.Errorf("verifyDiff: cannot drop existing tables for %s on %s: %s", t.Dir, workspace, err)
 		}
 	} else {
-		tempSchema, err = t.Instance.CreateSchema(tempSchemaName, t.Dir.Config.Get("default-character-set"), t.Dir.Config.Get("default-collation"))
+		tempSchema, err = workspace.CreateSchema(tempSchemaName, t.Dir.Config.Get("default-character-set"), t.Dir.Config.Get("default-collation"))
 		if err != nil {
-			return fmt.Errorf("verifyDiff: cannot create temporary schema for %s on %s: %s", t.Dir, t.Instance, err)
+			return fmt.Errorf("verifyDiff: cannot create temporary schema for %s on %s: %s", t.Dir, workspace, err)
 		}
 	}
-	if err = t.Instance.CloneSchema(t.SchemaFromInstance, tempSchema); err != nil {
-		return err
+
+	// Apply the schema-level "before" state, followed by any schema-level DDL.
+	// These mirror the logic used by push, which creates or alters the schema
+	// using its charset and collation rather than running SchemaDDL directly.
+	if t.SchemaFromInstance == nil {
+		err = workspace.AlterSchema(tempSchema, t.SchemaFromDir.CharSet, t.SchemaFromDir.Collation)
+	} else {
+		err = workspace.AlterSchema(tempSchema, t.SchemaFromInstance.CharSet, t.SchemaFromInstance.Collation)
+		if err == nil {
+			err = workspace.CloneSchema(t.SchemaFromInstance, tempSchema)
+		}
+		if err == nil && strings.HasPrefix(diff.SchemaDDL, "ALTER DATABASE") {
+			err = workspace.AlterSchema(tempSchema, t.SchemaFromDir.CharSet, t.SchemaFromDir.Collation)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("verifyDiff: cannot prepare temporary schema for %s on %s: %s", t.Dir, workspace, err)
 	}
 
-	db, err := t.Instance.Connect(tempSchemaName, "")
+	db, err := workspace.Connect(tempSchemaName, "foreign_key_checks=0")
 	if err != nil {
-		return fmt.Errorf("verifyDiff: cannot connect to %s: %s", t.Instance, err)
+		return fmt.Errorf("verifyDiff: cannot connect to %s: %s", workspace, err)
 	}
 	mods := tengo.StatementModifiers{
-		NextAutoInc: tengo.NextAutoIncIgnore,
+		NextAutoInc: tengo.NextAutoIncIfIncreased,
+		AllowUnsafe: true,
+		Flavor:      workspace.Flavor(),
 	}
 	tableNameToDDL := make(map[string]string)
 
-	// Iterate over the TableDiffs in the SchemaDiff. For any that are an ALTER,
-	// run it against the table in the temp schema, and see if the table now matches
-	// the version in the toTables map.
+	// Run every statement in the SchemaDiff against the temp schema
 	for _, tableDiff := range diff.TableDiffs {
-		alter, ok := tableDiff.(tengo.AlterTable)
-		if !ok {
-			continue
-		}
 		stmt, _ := tableDiff.Statement(mods) // fine to ignore errors for verifying DDL against temporary schema
 		if stmt == "" {
 			continue
 		}
 		if _, err = db.Exec(stmt); err != nil {
-			return err
+			return fmt.Errorf("verifyDiff: error running DDL in temporary schema: %s\nDDL:\n%s", err, stmt)
+		}
+		switch td := tableDiff.(type) {
+		case tengo.AlterTable:
+			tableNameToDDL[td.Table.Name] = stmt
+		case tengo.CreateTable:
+			tableNameToDDL[td.Table.Name] = stmt
+		case tengo.DropTable:
+			tableNameToDDL[td.Table.Name] = stmt
+		}
+	}
+
+	// Compare the entire resulting schema to SchemaFromDir
+	tempSchema.PurgeTableCache()
+	if err = t.compareVerifiedSchema(tempSchema, diff, tableNameToDDL); err != nil {
+		return err
+	}
+
+	// Clean up the temp schema
+	if t.Dir.Config.GetBool("reuse-temp-schema") {
+		if err = workspace.DropTablesInSchema(tempSchema, true); err != nil {
+			return fmt.Errorf("verifyDiff: cannot drop tables in temporary schema for %s on %s: %s", t.Dir, workspace, err)
+		}
+	} else {
+		if err = workspace.DropSchema(tempSchema, true); err != nil {
+			return fmt.Errorf("verifyDiff: cannot drop temporary schema for %s on %s: %s", t.Dir, workspace, err)
 		}
-		tableNameToDDL[alter.Table.Name] = stmt
 	}
-	postAlterTables, err := tempSchema.TablesByName()
+
+	return nil
+}
+
+// compareVerifiedSchema compares tempSchema, after having DDL applied to it by
+// verifyDiff, to SchemaFromDir. It returns an error describing the first
+// discrepancy found, if any. Tables in diff.UnsupportedTables are not compared,
+// since no DDL was generated for them; nor are tables with a skeema:ignore
+// annotation. tableNameToDDL is used to include the relevant DDL statement in
+// the error.
+func (t *Target) compareVerifiedSchema(tempSchema *tengo.Schema, diff *tengo.SchemaDiff, tableNameToDDL map[string]string) error {
+	const skipVerifyHint = "Run command again with --skip-verify if this discrepancy is safe to ignore"
+
+	if tempSchema.CharSet != t.SchemaFromDir.CharSet || tempSchema.Collation != t.SchemaFromDir.Collation {
+		return fmt.Errorf("verifyDiff: Failure on schema defaults\nEXPECTED: CHARACTER SET %s COLLATE %s\nACTUAL: CHARACTER SET %s COLLATE %s\n\n%s",
+			t.SchemaFromDir.CharSet, t.SchemaFromDir.Collation, tempSchema.CharSet, tempSchema.Collation, skipVerifyHint)
+	}
+
+	actualTables, err := tempSchema.TablesByName()
 	if err != nil {
 		return err
 	}
 	expectTables, _ := t.SchemaFromDir.TablesByName() // can ignore error since we know table list already cached
-
-	for name, stmt := range tableNameToDDL {
-		// We have to compare CREATE TABLE statements without their next auto-inc
-		// values, since divergence there may be expected depending on settings
-		expected, _ := tengo.ParseCreateAutoInc(expectTables[name].CreateStatement())
-		actual, _ := tengo.ParseCreateAutoInc(postAlterTables[name].CreateStatement())
-		if expected != actual {
-			return fmt.Errorf("verifyDiff: Failure on table %s\nDDL:\n%s\n\nEXPECTED POST-ALTER:\n%s\n\nACTUAL POST-ALTER:\n%s\n\nRun command again with --skip-verify if this discrepancy is safe to ignore", name, stmt, expected, actual)
+	for _, table := range diff.UnsupportedTables {
+		delete(actualTables, table.Name)
+		delete(expectTables, table.Name)
+	}
+	for name := range t.TableAnnotations {
+		if t.ignoresTable(name) {
+			delete(actualTables, name)
+			delete(expectTables, name)
 		}
 	}
 
-	// Clean up the temp schema
-	if t.Dir.Config.GetBool("reuse-temp-schema") {
-		if err = t.Instance.DropTablesInSchema(tempSchema, true); err != nil {
-			return fmt.Errorf("verifyDiff: cannot drop tables in temporary schema for %s on %s: %s", t.Dir, t.Instance, err)
+	for name, actualTable := range actualTables {
+		if _, ok := expectTables[name]; !ok && !isSoftDropped(name) {
+			return fmt.Errorf("verifyDiff: Failure on table %s\nDDL:\n%s\n\nTable exists after DDL, but should not\n\n%s", name, tableNameToDDL[name], skipVerifyHint)
+		} else if ok {
+			// We have to compare CREATE TABLE statements without their next auto-inc
+			// values, since divergence there may be expected depending on settings
+			expected, _ := tengo.ParseCreateAutoInc(expectTables[name].CreateStatement())
+			actual, _ := tengo.ParseCreateAutoInc(actualTable.CreateStatement())
+			if expected != actual {
+				return fmt.Errorf("verifyDiff: Failure on table %s\nDDL:\n%s\n\nEXPECTED POST-DDL:\n%s\n\nACTUAL POST-DDL:\n%s\n\n%s", name, tableNameToDDL[name], expected, actual, skipVerifyHint)
+			}
 		}
-	} else {
-		if err = t.Instance.DropSchema(tempSchema, true); err != nil {
-			return fmt.Errorf("verifyDiff: cannot drop temporary schema for %s on %s: %s", t.Dir, t.Instance, err)
+	}
+	for name := range expectTables {
+		if _, ok := actualTables[name]; !ok {
+			return fmt.Errorf("verifyDiff: Failure on table %s\nDDL:\n%s\n\nTable does not exist after DDL, but should\n\n%s", name, tableNameToDDL[name], skipVerifyHint)
 		}
 	}
+	return nil
+}
+
+// confirmPushed re-introspects the target's schema on its instance, and
+// confirms that it now matches the filesystem representation of the schema.
+// This is intended for use after changes have already been pushed. Differences
+// in next auto-increment values are ignored.
+func (t *Target) confirmPushed() error {
+	return t.confirmSchema(t.Instance)
+}
 
+// confirmSchema re-introspects the schema on instance, and confirms that it
+// matches t.SchemaFromDir. This may be used to check t.Instance after a push,
+// or a replica of it.
+func (t *Target) confirmSchema(instance *tengo.Instance) error {
+	schema, err := instance.Schema(t.SchemaFromDir.Name)
+	if err != nil {
+		return err
+	} else if schema == nil {
+		return fmt.Errorf("Schema %s does not exist on %s", t.SchemaFromDir.Name, instance)
+	}
+	schema.PurgeTableCache()
+
+	diff, err := tengo.NewSchemaDiff(schema, t.SchemaFromDir)
+	if err != nil {
+		return err
+	}
+	filterSoftDropped(diff)
+	t.filterIgnored(diff)
+	if diff.SchemaDDL != "" {
+		return fmt.Errorf("Schema-level defaults still differ: %s", diff.SchemaDDL)
+	}
+	mods := tengo.StatementModifiers{
+		NextAutoInc: tengo.NextAutoIncIgnore,
+		AllowUnsafe: true,
+		Flavor:      instance.Flavor(),
+	}
+	for _, tableDiff := range diff.TableDiffs {
+		if stmt, _ := tableDiff.Statement(mods); stmt != "" {
+			return fmt.Errorf("Differences remain after push: %s", stmt)
+		}
+	}
+	if len(diff.UnsupportedTables) > 0 {
+		return fmt.Errorf("Table %s still differs, and uses unsupported features", diff.UnsupportedTables[0].Name)
+	}
 	return nil
 }
 
@@ -313,10 +509,10 @@ func (t *Target) logUnsupportedTableDiff(name string) {
 
 	// Figure out which part is unsupported; this will determine what we're diffing
 	if dirTable, err := t.SchemaFromDir.Table(name); err == nil && dirTable != nil && dirTable.UnsupportedDDL {
-		expectedCreate = dirTable.GeneratedCreateStatement()
+		expectedCreate = dirTable.GeneratedCreateStatement(t.workspace().Flavor())
 		actualCreate = dirTable.CreateStatement()
 	} else if instTable, err := t.SchemaFromInstance.Table(name); err == nil && instTable != nil && instTable.UnsupportedDDL {
-		expectedCreate = instTable.GeneratedCreateStatement()
+		expectedCreate = instTable.GeneratedCreateStatement(t.Instance.Flavor())
 		actualCreate = instTable.CreateStatement()
 	} else if dirTable != nil && instTable != nil && dirTable.CreateStatement() != instTable.CreateStatement() {
 		expectedCreate = dirTable.CreateStatement()
@@ -343,7 +539,7 @@ func (t *Target) logUnsupportedTableDiff(name string) {
 }
 
 func (t *Target) lockTempSchema(maxWait time.Duration) (*sql.Tx, error) {
-	db, err := t.Instance.Connect("", "")
+	db, err := t.workspace().Connect("", "")
 	if err != nil {
 		return nil, err
 	}
@@ -353,7 +549,7 @@ func (t *Target) lockTempSchema(maxWait time.Duration) (*sql.Tx, error) {
 	}
 
 	var getLockResult int
-	lockName := fmt.Sprintf("skeema.%s", t.Dir.Config.Get("temp-schema"))
+	lockName := fmt.Sprintf("skeema.%s", TempSchemaName(t.Dir.Config))
 	start := time.Now()
 
 	for time.Since(start) < maxWait {
@@ -368,7 +564,7 @@ func (t *Target) lockTempSchema(maxWait time.Duration) (*sql.Tx, error) {
 }
 
 func (t *Target) unlockTempSchema(tx *sql.Tx) error {
-	lockName := fmt.Sprintf("skeema.%s", t.Dir.Config.Get("temp-schema"))
+	lockName := fmt.Sprintf("skeema.%s", TempSchemaName(t.Dir.Config))
 	var releaseLockResult int
 	err := tx.QueryRow("SELECT RELEASE_LOCK(?)", lockName).Scan(&releaseLockResult)
 	if err != nil || releaseLockResult != 1 {
@@ -376,3 +572,30 @@ func (t *Target) unlockTempSchema(tx *sql.Tx) error {
 	}
 	return tx.Rollback()
 }
+
+var warnedWorkspaceMismatch = make(map[string]bool)
+
+// warnWorkspaceVersionMismatch logs a warning if the workspace instance's
+// version or flavor differs from that of instance, since this may cause
+// temp schema operations to behave differently than they would on instance.
+// Each combination of workspace and instance is only warned about once.
+func warnWorkspaceVersionMismatch(workspace, instance *tengo.Instance) {
+	key := fmt.Sprintf("%s %s", workspace, instance)
+	if warnedWorkspaceMismatch[key] {
+		return
+	}
+	warnedWorkspaceMismatch[key] = true
+	workspaceFlavor, err := instanceFlavor(workspace)
+	if err != nil {
+		log.Warnf("Unable to determine version of workspace %s: %s", workspace, err)
+		return
+	}
+	targetFlavor, err := instanceFlavor(instance)
+	if err != nil {
+		log.Warnf("Unable to determine version of %s: %s", instance, err)
+		return
+	}
+	if workspaceFlavor.Series() != targetFlavor.Series() {
+		log.Warnf("Workspace %s is running %s, but %s is running %s. Results may be inaccurate.", workspace, workspaceFlavor, instance, targetFlavor)
+	}
+}
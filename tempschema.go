@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skeema/mycli"
+)
+
+// This file contains logic for naming the temporary schema. If the
+// unique-temp-schema option is enabled, a suffix is appended to the
+// temp-schema option value, so that concurrent runs of Skeema against the same
+// instance each use their own temp schema. The suffix encodes the creation
+// time, which permits `skeema cleanup` to identify orphaned temp schemas.
+
+var uniqueTempSchemaSuffix struct {
+	sync.Once
+	value string
+}
+
+// TempSchemaName returns the name of the temporary schema to use, based on
+// the temp-schema and unique-temp-schema options in cfg. When
+// unique-temp-schema is enabled, the same suffix is used for the lifetime of
+// the process.
+func TempSchemaName(cfg *mycli.Config) string {
+	name := cfg.Get("temp-schema")
+	if !cfg.GetBool("unique-temp-schema") {
+		return name
+	}
+	uniqueTempSchemaSuffix.Do(func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		uniqueTempSchemaSuffix.value = fmt.Sprintf("%d_%d_%04x", time.Now().Unix(), os.Getpid(), r.Intn(0x10000))
+	})
+	return fmt.Sprintf("%s_%s", name, uniqueTempSchemaSuffix.value)
+}
+
+// parseUniqueTempSchemaName determines whether name is a unique temp schema
+// name generated from the supplied base temp-schema name. If so, the time of
+// its creation is returned, along with true.
+func parseUniqueTempSchemaName(base, name string) (time.Time, bool) {
+	re := regexp.MustCompile(fmt.Sprintf(`^%s_(\d+)_\d+_[0-9a-f]{4}$`, regexp.QuoteMeta(base)))
+	matches := re.FindStringSubmatch(name)
+	if matches == nil {
+		return time.Time{}, false
+	}
+	ts, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(ts, 0), true
+}
+
+// isTempSchemaName returns true if name refers to the temp schema, or to any
+// unique temp schema, based on the configuration in cfg.
+func isTempSchemaName(cfg *mycli.Config, name string) bool {
+	if name == cfg.Get("temp-schema") || name == TempSchemaName(cfg) {
+		return true
+	}
+	_, ok := parseUniqueTempSchemaName(cfg.Get("temp-schema"), name)
+	return ok
+}
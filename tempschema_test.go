@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTempSchemaName(t *testing.T) {
+	cfg := getConfig(map[string]string{"temp-schema": "_skeema_tmp", "unique-temp-schema": ""}) // see dir_test.go
+	if name := TempSchemaName(cfg); name != "_skeema_tmp" {
+		t.Errorf("Expected TempSchemaName to return _skeema_tmp, instead found %s", name)
+	}
+
+	cfg = getConfig(map[string]string{"temp-schema": "_skeema_tmp", "unique-temp-schema": "1"})
+	name := TempSchemaName(cfg)
+	if name2 := TempSchemaName(cfg); name2 != name {
+		t.Errorf("Expected TempSchemaName to return same value on each call; instead found %s vs %s", name, name2)
+	}
+	createdAt, ok := parseUniqueTempSchemaName("_skeema_tmp", name)
+	if !ok {
+		t.Fatalf("Expected parseUniqueTempSchemaName to parse %s, but it did not", name)
+	}
+	if since := time.Since(createdAt); since < 0 || since > time.Minute {
+		t.Errorf("Unexpected creation time %s parsed from %s", createdAt, name)
+	}
+	for _, other := range []string{name, "_skeema_tmp", "_skeema_tmp_1529001000_4242_9f3c"} {
+		if !isTempSchemaName(cfg, other) {
+			t.Errorf("Expected isTempSchemaName to return true for %s, but it did not", other)
+		}
+	}
+}
+
+func TestParseUniqueTempSchemaName(t *testing.T) {
+	createdAt, ok := parseUniqueTempSchemaName("_skeema_tmp", "_skeema_tmp_1529001000_4242_9f3c")
+	if !ok || !createdAt.Equal(time.Unix(1529001000, 0)) {
+		t.Errorf("Unexpected result from parseUniqueTempSchemaName: %s, %t", createdAt, ok)
+	}
+	if _, ok := parseUniqueTempSchemaName("tmp.x", "tmp.x_1529001000_4242_9f3c"); !ok {
+		t.Error("Expected parseUniqueTempSchemaName to handle base name containing regexp metacharacters")
+	}
+	for _, name := range []string{"_skeema_tmp", "_skeema_tmp_1529001000_4242", "_skeema_tmp_1529001000_4242_9F3C", "x_skeema_tmp_1529001000_4242_9f3c", "_skeema_tmpx1529001000_4242_9f3c", "tmpxx_1529001000_4242_9f3c"} {
+		if _, ok := parseUniqueTempSchemaName("_skeema_tmp", name); ok {
+			t.Errorf("Expected parseUniqueTempSchemaName to reject %s, but it did not", name)
+		}
+	}
+}
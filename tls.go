@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// This file contains logic for configuring TLS on database connections, based
+// on the ssl-mode, ssl-ca, ssl-cert, and ssl-key options. These mirror the
+// options of the same names in the standard mysql client. A custom tls.Config
+// is registered with the driver for each distinct combination of settings.
+
+var registeredTLSConfigs struct {
+	sync.Mutex
+	byKey map[string]bool
+}
+
+// SSLMode returns the effective value of the ssl-mode option for dir. If
+// ssl-mode is not set explicitly, it defaults to "VERIFY_CA" if ssl-ca is set,
+// "REQUIRED" if ssl-cert or ssl-key is set, or a blank string (meaning that
+// the driver's TLS settings are left as-is) otherwise.
+func (dir *Dir) SSLMode() (string, error) {
+	mode, err := dir.Config.GetEnum("ssl-mode", "DISABLED", "REQUIRED", "VERIFY_CA", "VERIFY_IDENTITY")
+	if err != nil || mode != "" {
+		return mode, err
+	}
+	if dir.Config.Get("ssl-ca") != "" {
+		return "VERIFY_CA", nil
+	} else if dir.Config.Get("ssl-cert") != "" || dir.Config.Get("ssl-key") != "" {
+		return "REQUIRED", nil
+	}
+	return "", nil
+}
+
+// TLSParam returns a value for the driver's tls DSN param, for a TCP
+// connection to the supplied host, based on dir's configuration. If needed, a
+// custom TLS config is registered with the driver. A blank string is returned
+// if the ssl options are not in use.
+func (dir *Dir) TLSParam(host string) (string, error) {
+	mode, err := dir.SSLMode()
+	if err != nil || mode == "" {
+		return "", err
+	}
+	options, _ := SplitConnectOptions(dir.Config.Get("connect-options")) // errors already handled by InstanceDefaultParams
+	for name := range options {
+		if strings.ToLower(name) == "tls" {
+			return "", errors.New("connect-options may not contain tls if any ssl options are set")
+		}
+	}
+	if mode == "DISABLED" {
+		return "false", nil
+	}
+
+	caPath, certPath, keyPath := dir.Config.Get("ssl-ca"), dir.Config.Get("ssl-cert"), dir.Config.Get("ssl-key")
+	if mode != "VERIFY_IDENTITY" {
+		host = "" // only VERIFY_IDENTITY needs a separate config per host
+	}
+	key := fmt.Sprintf("skeema_%x", sha1.Sum([]byte(strings.Join([]string{mode, caPath, certPath, keyPath, host}, "\x00"))))
+
+	registeredTLSConfigs.Lock()
+	defer registeredTLSConfigs.Unlock()
+	if registeredTLSConfigs.byKey[key] {
+		return key, nil
+	}
+	config, err := newTLSConfig(mode, caPath, certPath, keyPath, host)
+	if err != nil {
+		return "", err
+	}
+	if err := mysql.RegisterTLSConfig(key, config); err != nil {
+		return "", err
+	}
+	if registeredTLSConfigs.byKey == nil {
+		registeredTLSConfigs.byKey = make(map[string]bool)
+	}
+	registeredTLSConfigs.byKey[key] = true
+	return key, nil
+}
+
+// addTLSParam appends the supplied tls param value, if non-blank, to a param
+// string obtained from InstanceDefaultParams.
+func addTLSParam(params, tlsValue string) string {
+	if tlsValue == "" {
+		return params
+	}
+	return fmt.Sprintf("%s&tls=%s", params, url.QueryEscape(tlsValue))
+}
+
+// newTLSConfig returns a tls.Config for the supplied ssl-mode and file paths.
+// Any of the file paths may be blank, although ssl-cert and ssl-key must be
+// supplied together, and VERIFY_CA requires ssl-ca. For VERIFY_IDENTITY, the
+// server's certificate must be valid for serverName.
+func newTLSConfig(mode, caPath, certPath, keyPath, serverName string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if caPath != "" {
+		pem, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read ssl-ca: %s", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No valid certificates found in ssl-ca file %s", caPath)
+		}
+	}
+	if certPath != "" || keyPath != "" {
+		if certPath == "" || keyPath == "" {
+			return nil, errors.New("Options ssl-cert and ssl-key must be supplied together")
+		}
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load ssl-cert and ssl-key: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	switch mode {
+	case "REQUIRED":
+		config.InsecureSkipVerify = true
+	case "VERIFY_CA":
+		if config.RootCAs == nil {
+			return nil, errors.New("ssl-mode=VERIFY_CA requires ssl-ca to be set")
+		}
+		// Verify the certificate chain ourselves, since the standard verification
+		// also requires the hostname to match
+		roots := config.RootCAs
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertChain(rawCerts, roots)
+		}
+	case "VERIFY_IDENTITY":
+		config.ServerName = serverName
+	}
+	return config, nil
+}
+
+// verifyCertChain confirms that the first certificate in rawCerts chains to
+// one of roots, using any additional certificates in rawCerts as
+// intermediates. The hostname is not checked.
+func verifyCertChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("Server did not supply a certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for n, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[n] = cert
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSSLMode(t *testing.T) {
+	assertMode := func(expected string, expectError bool, pairs ...string) {
+		t.Helper()
+		optValues := map[string]string{"ssl-mode": "", "ssl-ca": "", "ssl-cert": "", "ssl-key": ""}
+		for n := 0; n < len(pairs); n += 2 {
+			optValues[pairs[n]] = pairs[n+1]
+		}
+		dir := &Dir{
+			Path:    "/tmp/dummydir",
+			Config:  getConfig(optValues), // see dir_test.go
+			section: "production",
+		}
+		mode, err := dir.SSLMode()
+		if expectError && err == nil {
+			t.Errorf("Expected SSLMode to return error for %v, but it did not", optValues)
+		} else if !expectError && err != nil {
+			t.Errorf("Unexpected error from SSLMode for %v: %s", optValues, err)
+		} else if mode != expected {
+			t.Errorf("Expected SSLMode to return %q for %v, instead found %q", expected, optValues, mode)
+		}
+	}
+	assertMode("", false)
+	assertMode("VERIFY_CA", false, "ssl-ca", "/etc/ssl/ca.pem")
+	assertMode("REQUIRED", false, "ssl-cert", "/etc/ssl/cert.pem", "ssl-key", "/etc/ssl/key.pem")
+	assertMode("VERIFY_IDENTITY", false, "ssl-mode", "verify_identity", "ssl-ca", "/etc/ssl/ca.pem")
+	assertMode("DISABLED", false, "ssl-mode", "Disabled", "ssl-ca", "/etc/ssl/ca.pem")
+	assertMode("", true, "ssl-mode", "PREFERRED")
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	notPEM := filepath.Join(tmpdir, "bogus.pem")
+	if err := ioutil.WriteFile(notPEM, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+
+	config, err := newTLSConfig("REQUIRED", "", "", "", "")
+	if err != nil {
+		t.Errorf("Unexpected error from newTLSConfig: %s", err)
+	} else if !config.InsecureSkipVerify {
+		t.Error("Expected ssl-mode=REQUIRED to skip certificate verification, but it did not")
+	}
+	config, err = newTLSConfig("VERIFY_IDENTITY", "", "", "", "db1.example.com")
+	if err != nil {
+		t.Errorf("Unexpected error from newTLSConfig: %s", err)
+	} else if config.InsecureSkipVerify || config.ServerName != "db1.example.com" {
+		t.Errorf("Unexpected tls.Config for ssl-mode=VERIFY_IDENTITY: InsecureSkipVerify=%t ServerName=%s", config.InsecureSkipVerify, config.ServerName)
+	}
+
+	badArgs := [][]string{
+		{"VERIFY_CA", "", "", ""},
+		{"VERIFY_CA", notPEM, "", ""},
+		{"REQUIRED", filepath.Join(tmpdir, "doesnt-exist.pem"), "", ""},
+		{"REQUIRED", "", notPEM, ""},
+		{"REQUIRED", "", "", notPEM},
+		{"REQUIRED", "", notPEM, notPEM},
+	}
+	for _, args := range badArgs {
+		if _, err := newTLSConfig(args[0], args[1], args[2], args[3], ""); err == nil {
+			t.Errorf("Expected newTLSConfig%v to return an error, but it did not", args)
+		}
+	}
+}
+
+func TestAddTLSParam(t *testing.T) {
+	if actual := addTLSParam("timeout=5s", ""); actual != "timeout=5s" {
+		t.Errorf("Unexpected result from addTLSParam: %s", actual)
+	}
+	if actual := addTLSParam("timeout=5s", "skeema_abc"); actual != "timeout=5s&tls=skeema_abc" {
+		t.Errorf("Unexpected result from addTLSParam: %s", actual)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/skeema/tengo"
+)
+
+// This file contains logic for auto-provisioning a throwaway local mysqld
+// process, for use as a workspace instance for temp schema operations. The
+// process is started on first use, and shut down when Skeema exits.
+
+// localWorkspace represents a temporary mysqld process running on the local
+// machine, with its data directory and socket in a temporary directory.
+type localWorkspace struct {
+	mysqld   string
+	basedir  string
+	tmpdir   string
+	cmd      *exec.Cmd
+	exited   chan error
+	instance *tengo.Instance
+}
+
+var localWorkspaces struct {
+	sync.Mutex
+	byPath map[string]*localWorkspace
+}
+
+// localWorkspacePaths interprets a value of the workspace-basedir option,
+// which may refer to either a MySQL base directory or a mysqld binary. It
+// returns the base directory and the path to the mysqld binary.
+func localWorkspacePaths(value string) (basedir, mysqld string, err error) {
+	value, err = filepath.Abs(value)
+	if err != nil {
+		return "", "", err
+	}
+	fi, err := os.Stat(value)
+	if err != nil {
+		return "", "", err
+	}
+	if fi.IsDir() {
+		basedir, mysqld = value, filepath.Join(value, "bin", "mysqld")
+		if fi, err = os.Stat(mysqld); err != nil {
+			return "", "", err
+		}
+	} else {
+		basedir, mysqld = filepath.Dir(filepath.Dir(value)), value
+	}
+	if fi.IsDir() || fi.Mode()&0111 == 0 {
+		return "", "", fmt.Errorf("%s is not an executable file", mysqld)
+	}
+	return basedir, mysqld, nil
+}
+
+var reMysqldVersion = regexp.MustCompile(`Ver (\S+)(?: for .*?)?(?: \((.*)\))?\s*$`)
+
+// mysqldFlavor parses the output of `mysqld --version` into a tengo.Flavor.
+// If the output cannot be parsed, tengo.FlavorUnknown is returned.
+func mysqldFlavor(output string) tengo.Flavor {
+	matches := reMysqldVersion.FindStringSubmatch(strings.TrimSpace(output))
+	if matches == nil {
+		return tengo.FlavorUnknown
+	}
+	return tengo.ParseFlavor(matches[1], matches[2])
+}
+
+// checkLocalWorkspaceFlavor returns an error if the mysqld binary does not
+// support initializing a data directory with --initialize-insecure. MariaDB
+// requires the separate mysql_install_db script instead, which is not
+// supported, and MySQL only supports --initialize-insecure in 5.7+. If the
+// version cannot be determined, no error is returned, and any problem will be
+// reported when the data directory is initialized.
+func checkLocalWorkspaceFlavor(mysqld string) error {
+	output, err := exec.Command(mysqld, "--version").Output()
+	if err != nil {
+		log.Debugf("Unable to determine version of %s: %s", mysqld, err)
+		return nil
+	}
+	flavor := mysqldFlavor(string(output))
+	if flavor.IsMariaDB() {
+		return fmt.Errorf("%s is %s, but MariaDB is not supported by workspace-basedir, since it cannot initialize a data directory using mysqld --initialize-insecure. Use workspace-host instead", mysqld, flavor)
+	} else if flavor.Known() && !flavor.AtLeast(5, 7, 0) {
+		return fmt.Errorf("%s is %s, but workspace-basedir requires MySQL 5.7 or later", mysqld, flavor)
+	}
+	return nil
+}
+
+// LocalWorkspaceInstance returns an Instance for a local mysqld process based
+// on the supplied workspace-basedir option value. The process is started if it
+// is not already running; it will be shut down automatically upon Exit.
+func LocalWorkspaceInstance(value string, params string, startTimeout time.Duration) (*tengo.Instance, error) {
+	basedir, mysqld, err := localWorkspacePaths(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid workspace-basedir: %s", err)
+	}
+
+	localWorkspaces.Lock()
+	defer localWorkspaces.Unlock()
+	if lw, ok := localWorkspaces.byPath[mysqld]; ok {
+		return lw.instance, nil
+	}
+	if localWorkspaces.byPath == nil {
+		localWorkspaces.byPath = make(map[string]*localWorkspace)
+		handleInterrupts()
+	}
+
+	if err := checkLocalWorkspaceFlavor(mysqld); err != nil {
+		return nil, fmt.Errorf("Invalid workspace-basedir: %s", err)
+	}
+
+	lw := &localWorkspace{
+		mysqld:  mysqld,
+		basedir: basedir,
+	}
+	if lw.tmpdir, err = ioutil.TempDir("", "skeema-workspace"); err != nil {
+		return nil, err
+	}
+	localWorkspaces.byPath[mysqld] = lw
+	if err = lw.start(params, startTimeout); err != nil {
+		lw.shutdown()
+		delete(localWorkspaces.byPath, mysqld)
+		return nil, err
+	}
+	return lw.instance, nil
+}
+
+// start initializes a data directory, starts mysqld, and waits for it to
+// accept connections.
+func (lw *localWorkspace) start(params string, startTimeout time.Duration) error {
+	datadir := filepath.Join(lw.tmpdir, "data")
+	socket := filepath.Join(lw.tmpdir, "mysql.sock")
+	logFile := filepath.Join(lw.tmpdir, "error.log")
+	args := []string{
+		"--no-defaults",
+		"--basedir=" + lw.basedir,
+		"--datadir=" + datadir,
+		"--log-error=" + logFile,
+	}
+	if os.Geteuid() == 0 {
+		args = append(args, "--user=root")
+	}
+
+	log.Infof("Initializing temporary workspace data directory %s using %s", datadir, lw.mysqld)
+	initArgs := append([]string{}, args...)
+	initArgs = append(initArgs, "--initialize-insecure")
+	if err := exec.Command(lw.mysqld, initArgs...).Run(); err != nil {
+		return fmt.Errorf("Unable to initialize workspace data directory: %s%s", err, logTail(logFile))
+	}
+
+	args = append(args, "--socket="+socket, "--pid-file="+filepath.Join(lw.tmpdir, "mysqld.pid"), "--skip-networking")
+	lw.cmd = exec.Command(lw.mysqld, args...)
+	lw.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} // don't receive terminal signals such as ctrl-c
+	if err := lw.cmd.Start(); err != nil {
+		return fmt.Errorf("Unable to start workspace mysqld: %s", err)
+	}
+	lw.exited = make(chan error, 1)
+	go func() {
+		lw.exited <- lw.cmd.Wait()
+	}()
+
+	instance, err := tengo.NewInstance("mysql", fmt.Sprintf("root@unix(%s)/?%s", socket, params))
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(startTimeout)
+	for {
+		if ok, _ := instance.CanConnect(); ok {
+			break
+		}
+		select {
+		case err := <-lw.exited:
+			lw.exited <- err
+			return fmt.Errorf("Workspace mysqld exited unexpectedly during startup: %v%s", err, logTail(logFile))
+		case <-time.After(250 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Workspace mysqld did not accept connections within %s%s", startTimeout, logTail(logFile))
+		}
+	}
+	log.Infof("Started temporary workspace mysqld on socket %s", socket)
+	lw.instance = instance
+	return nil
+}
+
+// shutdown stops the mysqld process, if running, and removes its temporary
+// directory.
+func (lw *localWorkspace) shutdown() error {
+	var err error
+	if lw.cmd != nil && lw.cmd.Process != nil {
+		select {
+		case <-lw.exited: // already exited
+		default:
+			lw.cmd.Process.Signal(syscall.SIGTERM)
+			select {
+			case <-lw.exited:
+			case <-time.After(30 * time.Second):
+				lw.cmd.Process.Kill()
+				<-lw.exited
+				err = errors.New("workspace mysqld did not shut down within 30s, killed it")
+			}
+		}
+	}
+	if removeErr := os.RemoveAll(lw.tmpdir); removeErr != nil && err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// shutdownLocalWorkspaces shuts down all local workspace mysqld processes
+// started by this process. It should be called prior to exiting.
+func shutdownLocalWorkspaces() {
+	localWorkspaces.Lock()
+	defer localWorkspaces.Unlock()
+	for path, lw := range localWorkspaces.byPath {
+		log.Debugf("Shutting down temporary workspace mysqld %s", path)
+		if err := lw.shutdown(); err != nil {
+			log.Warnf("Unable to cleanly shut down temporary workspace %s: %s", lw.tmpdir, err)
+		}
+		delete(localWorkspaces.byPath, path)
+	}
+}
+
+var handleInterruptsOnce sync.Once
+
+// handleInterrupts ensures that local workspaces and SSH tunnels are shut down
+// if Skeema is interrupted or terminated by a signal. It is safe to call
+// multiple times.
+func handleInterrupts() {
+	handleInterruptsOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-signals
+			Exit(NewExitValue(CodeFatalError, "Received signal: %s", sig))
+		}()
+	})
+}
+
+// logTail returns the last 1KB of the file at path, formatted for appending
+// to an error message. If the file cannot be read, a blank string
+// is returned.
+func logTail(path string) string {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil || len(contents) == 0 {
+		return ""
+	}
+	const maxBytes = 1024
+	if len(contents) > maxBytes {
+		contents = contents[len(contents)-maxBytes:]
+	}
+	return fmt.Sprintf("\nEnd of %s:\n%s", path, contents)
+}
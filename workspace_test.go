@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalWorkspacePaths(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	bindir := filepath.Join(tmpdir, "bin")
+	if err := os.Mkdir(bindir, 0755); err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	mysqld := filepath.Join(bindir, "mysqld")
+	if err := ioutil.WriteFile(mysqld, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	notExecutable := filepath.Join(bindir, "README")
+	if err := ioutil.WriteFile(notExecutable, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+
+	for _, value := range []string{tmpdir, mysqld} {
+		basedir, binary, err := localWorkspacePaths(value)
+		if err != nil {
+			t.Errorf("Unexpected error from localWorkspacePaths(\"%s\"): %s", value, err)
+		} else if basedir != tmpdir || binary != mysqld {
+			t.Errorf("Expected localWorkspacePaths(\"%s\") to return %s,%s; instead found %s,%s", value, tmpdir, mysqld, basedir, binary)
+		}
+	}
+	for _, value := range []string{bindir, notExecutable, filepath.Join(tmpdir, "doesnt-exist")} {
+		if _, _, err := localWorkspacePaths(value); err == nil {
+			t.Errorf("Expected localWorkspacePaths(\"%s\") to return an error, but it did not", value)
+		}
+	}
+}
+
+func TestMysqldFlavor(t *testing.T) {
+	cases := map[string]string{
+		"/usr/sbin/mysqld  Ver 8.0.13 for Linux on x86_64 (MySQL Community Server - GPL)\n":                                             "mysql:8.0.13",
+		"/usr/sbin/mysqld  Ver 5.7.21-20 for debian-linux-gnu on x86_64 (Percona Server (GPL), Release '20', Revision 'ed217b06ca3')\n": "percona:5.7.21",
+		"/usr/sbin/mysqld  Ver 10.3.9-MariaDB-1:10.3.9+maria~bionic for debian-linux-gnu on x86_64 (mariadb.org binary distribution)\n": "mariadb:10.3.9",
+		"/usr/local/mysql/bin/mysqld  Ver 5.6.38 for osx10.12 on x86_64 (MySQL Community Server (GPL))":                                 "mysql:5.6.38",
+		"hello world": "unknown",
+	}
+	for output, expected := range cases {
+		if actual := mysqldFlavor(output).String(); actual != expected {
+			t.Errorf("Expected mysqldFlavor(%q) to return %s, instead found %s", output, expected, actual)
+		}
+	}
+}
+
+func TestCheckLocalWorkspaceFlavor(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "skeema-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpdir)
+	mysqld := filepath.Join(tmpdir, "mysqld")
+	assertCheck := func(versionOutput string, expectError bool) {
+		t.Helper()
+		script := fmt.Sprintf("#!/bin/sh\necho '%s'\n", versionOutput)
+		if err := ioutil.WriteFile(mysqld, []byte(script), 0755); err != nil {
+			t.Fatalf("Unable to write file: %s", err)
+		}
+		if err := checkLocalWorkspaceFlavor(mysqld); err != nil && !expectError {
+			t.Errorf("Unexpected error from checkLocalWorkspaceFlavor for %q: %s", versionOutput, err)
+		} else if err == nil && expectError {
+			t.Errorf("Expected error from checkLocalWorkspaceFlavor for %q, but no error returned", versionOutput)
+		}
+	}
+	assertCheck("mysqld  Ver 8.0.13 for Linux on x86_64 (MySQL Community Server - GPL)", false)
+	assertCheck("mysqld  Ver 10.3.9-MariaDB for debian-linux-gnu on x86_64 (mariadb.org binary distribution)", true)
+	assertCheck("mysqld  Ver 5.6.38 for Linux on x86_64 (MySQL Community Server (GPL))", true)
+	assertCheck("something unexpected", false)
+}